@@ -20,7 +20,11 @@ import (
 
 	"github.com/snyk/cli-extension-iac-rules/internal/constants"
 	initWorkflow "github.com/snyk/cli-extension-iac-rules/internal/init"
+	"github.com/snyk/cli-extension-iac-rules/internal/metadata"
 	"github.com/snyk/cli-extension-iac-rules/internal/push"
+	"github.com/snyk/cli-extension-iac-rules/internal/relation"
+	"github.com/snyk/cli-extension-iac-rules/internal/remove"
+	"github.com/snyk/cli-extension-iac-rules/internal/rename"
 	"github.com/snyk/cli-extension-iac-rules/internal/repl"
 	"github.com/snyk/cli-extension-iac-rules/internal/test"
 )
@@ -38,6 +42,18 @@ func Init(e workflow.Engine) error {
 	if err := repl.RegisterWorkflows(e); err != nil {
 		return err
 	}
+	if err := rename.RegisterWorkflows(e); err != nil {
+		return err
+	}
+	if err := remove.RegisterWorkflows(e); err != nil {
+		return err
+	}
+	if err := metadata.RegisterWorkflows(e); err != nil {
+		return err
+	}
+	if err := relation.RegisterWorkflows(e); err != nil {
+		return err
+	}
 	config_utils.AddFeatureFlagToConfig(e, constants.FF_IAC_NEW_ENGINE, constants.FF_IAC_NEW_ENGINE)
 	return nil
 }
@@ -0,0 +1,311 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/policy-engine/pkg/input"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/init/forms"
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	flagType               = "type"
+	flagAnswers            = "answers"
+	flagName               = "name"
+	flagID                 = "id"
+	flagTitle              = "title"
+	flagSeverity           = "severity"
+	flagDescription        = "description"
+	flagProduct            = "product"
+	flagInputType          = "input-type"
+	flagResourceType       = "resource-type"
+	flagRelation           = "relation"
+	flagLink               = "link"
+	flagPrimaryAttribute   = "primary-attribute"
+	flagSecondaryAttribute = "secondary-attribute"
+	flagTemplate           = "template"
+	flagRegoTests          = "rego-tests"
+	flagRemediation        = "remediation"
+	flagReference          = "reference"
+	flagControl            = "control"
+)
+
+// Answers pre-populates the init forms so that they can run without
+// prompting. They are read from an answers file (YAML or JSON) and from
+// flags, with flags taking precedence.
+type Answers struct {
+	Type                string   `yaml:"type"`
+	Name                string   `yaml:"name"`
+	ID                  string   `yaml:"id"`
+	Title               string   `yaml:"title"`
+	Severity            string   `yaml:"severity"`
+	Description         string   `yaml:"description"`
+	Product             []string `yaml:"product"`
+	InputType           string   `yaml:"input_type"`
+	ResourceTypes       []string `yaml:"resource_types"`
+	Relation            string   `yaml:"relation"`
+	Links               []string `yaml:"links"`
+	PrimaryAttributes   []string `yaml:"primary_attributes"`
+	SecondaryAttributes []string `yaml:"secondary_attributes"`
+	Template            string   `yaml:"template"`
+	RegoTests           *bool    `yaml:"rego_tests"`
+	Remediation         string   `yaml:"remediation"`
+	References          []string `yaml:"references"`
+	Controls            []string `yaml:"controls"`
+}
+
+func addAnswersFlags(flagset *pflag.FlagSet) {
+	flagset.String(flagType, "", "What to initialize: project, rule, spec, relation or catalog (same as the argument)")
+	flagset.String(flagAnswers, "", "Path to a YAML or JSON file with answers to the prompts")
+	flagset.String(flagName, "", "Project, spec or relation name")
+	flagset.String(flagID, "", "Rule ID")
+	flagset.String(flagTitle, "", "Rule title")
+	flagset.String(flagSeverity, "", "Rule severity")
+	flagset.String(flagDescription, "", "Rule description")
+	flagset.StringSlice(flagProduct, nil, "Products the rule is intended for: iac, cloud or both")
+	flagset.String(flagInputType, "", "Input type of the rule or spec")
+	flagset.StringSlice(flagResourceType, nil, "Resource type (repeat for multi-resource rules and relations)")
+	flagset.String(flagRelation, "", "Relation used by a multi-resource rule")
+	flagset.StringSlice(flagLink, nil, "Relation of each further resource type of a multi-resource rule, as <relation> or <from resource type>=<relation> (can be repeated)")
+	flagset.StringSlice(flagPrimaryAttribute, nil, "Attribute of the primary resource type of a relation")
+	flagset.StringSlice(flagSecondaryAttribute, nil, "Attribute of the secondary resource type of a relation")
+	flagset.String(flagTemplate, "", "Name of the rule, spec or relation template")
+	flagset.Bool(flagRegoTests, false, "Generate a main_test.rego file with example tests for the rule")
+	flagset.String(flagRemediation, "", "Remediation guidance for the rule")
+	flagset.StringSlice(flagReference, nil, "URL of documentation about the rule (can be repeated)")
+	flagset.StringSlice(flagControl, nil, "Compliance control of the rule as framework:version:control (can be repeated)")
+}
+
+// answersFromConfig reads the answers file, if any, and overrides its values
+// with the ones given as flags. What to initialize can also be given as the
+// positional argument, e.g. `init rule`.
+func answersFromConfig(config configuration.Configuration) (Answers, error) {
+	var answers Answers
+	if path := config.GetString(flagAnswers); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return answers, err
+		}
+		// JSON is valid YAML, so this handles both formats.
+		if err := yaml.Unmarshal(b, &answers); err != nil {
+			return answers, fmt.Errorf("failed to parse answers file %s: %w", path, err)
+		}
+	}
+	for flag, field := range map[string]*string{
+		flagType:        &answers.Type,
+		flagName:        &answers.Name,
+		flagID:          &answers.ID,
+		flagTitle:       &answers.Title,
+		flagSeverity:    &answers.Severity,
+		flagDescription: &answers.Description,
+		flagInputType:   &answers.InputType,
+		flagRelation:    &answers.Relation,
+		flagTemplate:    &answers.Template,
+		flagRemediation: &answers.Remediation,
+	} {
+		if value := config.GetString(flag); value != "" {
+			*field = value
+		}
+	}
+	for flag, field := range map[string]*[]string{
+		flagProduct:            &answers.Product,
+		flagResourceType:       &answers.ResourceTypes,
+		flagLink:               &answers.Links,
+		flagPrimaryAttribute:   &answers.PrimaryAttributes,
+		flagSecondaryAttribute: &answers.SecondaryAttributes,
+		flagReference:          &answers.References,
+		flagControl:            &answers.Controls,
+	} {
+		if value := config.GetStringSlice(flag); len(value) > 0 {
+			*field = value
+		}
+	}
+	if config.IsSet(flagRegoTests) {
+		regoTests := config.GetBool(flagRegoTests)
+		answers.RegoTests = &regoTests
+	}
+	switch args := utils.PositionalArgs(config); len(args) {
+	case 0:
+	case 1:
+		if answers.Type != "" && config.GetString(flagType) != "" && !strings.EqualFold(answers.Type, args[0]) {
+			return answers, fmt.Errorf("cannot initialize both a %s and a %s", args[0], answers.Type)
+		}
+		answers.Type = args[0]
+	default:
+		return answers, fmt.Errorf("expected what to initialize as the only argument, got: %s", strings.Join(args, " "))
+	}
+	return answers, nil
+}
+
+// typeChoice maps what to initialize, given as the argument or with --type, to
+// one of the type choices.
+func (a Answers) typeChoice() (TypeChoice, error) {
+	switch strings.ToLower(a.Type) {
+	case "":
+		return "", nil
+	case TypeProject:
+		return TypeProject, nil
+	case TypeRule:
+		return TypeRule, nil
+	case "spec", TypeSpec:
+		return TypeSpec, nil
+	case TypeRelation:
+		return TypeRelation, nil
+	case "catalog", TypeCatalog:
+		return TypeCatalog, nil
+	}
+	return "", fmt.Errorf("unknown type '%s', must be one of: project, rule, spec, relation, catalog", a.Type)
+}
+
+func (a Answers) ruleFields() forms.RuleFields {
+	fields := forms.RuleFields{
+		RuleID:        a.ID,
+		Title:         a.Title,
+		Severity:      a.Severity,
+		Description:   a.Description,
+		InputType:     a.InputType,
+		ResourceTypes: a.ResourceTypes,
+		Relation:      a.Relation,
+		Links:         a.Links,
+		Template:      a.Template,
+		RegoTests:     a.RegoTests,
+	}
+	if a.Remediation != "" || len(a.References) > 0 || len(a.Controls) > 0 {
+		fields.Compliance = &forms.ComplianceFields{
+			Remediation: a.Remediation,
+			References:  a.References,
+			Controls:    a.Controls,
+		}
+	}
+	for _, p := range a.Product {
+		if p == "both" {
+			fields.Product = append(fields.Product, "iac", "cloud")
+		} else {
+			fields.Product = append(fields.Product, p)
+		}
+	}
+	if fields.InputType == "" && len(fields.Product) == 1 && fields.Product[0] == "cloud" {
+		// Mirrors the product prompt: cloud-only rules always use cloud scan
+		// inputs.
+		fields.InputType = input.CloudScan.Name
+	}
+	return fields
+}
+
+// missingRuleFields lists the flags that are needed to create a rule without
+// prompting.
+func missingRuleFields(fields forms.RuleFields) []string {
+	var missing []string
+	for flag, value := range map[string]string{
+		flagID:          fields.RuleID,
+		flagTitle:       fields.Title,
+		flagSeverity:    fields.Severity,
+		flagDescription: fields.Description,
+		flagInputType:   fields.InputType,
+	} {
+		if value == "" {
+			missing = append(missing, flag)
+		}
+	}
+	if len(fields.Product) == 0 {
+		missing = append(missing, flagProduct)
+	}
+	if len(fields.ResourceTypes) == 0 {
+		missing = append(missing, flagResourceType)
+	}
+	if len(fields.ResourceTypes) > 1 && fields.Relation == "" {
+		missing = append(missing, flagRelation)
+	}
+	if len(fields.ResourceTypes) > 2 && len(fields.Links) == 0 {
+		missing = append(missing, flagLink)
+	}
+	return missing
+}
+
+func missingSpecFields(fields forms.SpecFields, resourceTypes []string) []string {
+	var missing []string
+	if fields.RuleID == "" {
+		missing = append(missing, flagID)
+	}
+	if fields.Name == "" {
+		missing = append(missing, flagName)
+	}
+	if fields.InputType == "" {
+		missing = append(missing, flagInputType)
+	}
+	if fields.InputType == input.CloudScan.Name && len(resourceTypes) == 0 {
+		missing = append(missing, flagResourceType)
+	}
+	return missing
+}
+
+func missingRelationFields(fields forms.RelationFields) []string {
+	var missing []string
+	if fields.Name == "" {
+		missing = append(missing, flagName)
+	}
+	if fields.PrimaryResourceType == "" || fields.SecondaryResourceType == "" {
+		missing = append(missing, flagResourceType)
+	}
+	if len(fields.PrimaryAttributes) == 0 {
+		missing = append(missing, flagPrimaryAttribute)
+	}
+	if len(fields.SecondaryAttributes) == 0 {
+		missing = append(missing, flagSecondaryAttribute)
+	}
+	return missing
+}
+
+// interactive returns true if we can prompt for missing answers.
+func interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// template returns the template from the answers. Without a TTY to choose
+// one, it defaults to the default template.
+func (a Answers) template() string {
+	if a.Template == "" && !interactive() {
+		return forms.DefaultTemplate
+	}
+	return a.Template
+}
+
+// requireAnswers fails when answers are missing and there is no TTY to prompt
+// for them.
+func requireAnswers(kind string, missing []string) error {
+	if len(missing) == 0 || interactive() {
+		return nil
+	}
+	flags := make([]string, len(missing))
+	for i, m := range missing {
+		flags[i] = "--" + m
+	}
+	sort.Strings(flags)
+	return fmt.Errorf(
+		"missing required fields for a %s: %s. No TTY is available to prompt for them, pass them as flags or in an answers file",
+		kind,
+		strings.Join(flags, ", "),
+	)
+}
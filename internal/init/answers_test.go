@@ -0,0 +1,149 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/policy-engine/pkg/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/init/forms"
+)
+
+func TestAnswersFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+type: rule
+id: ACME_001
+title: Bucket is public
+severity: low
+description: Public buckets are bad
+product: [both]
+input_type: tf
+resource_types: [aws_s3_bucket, aws_s3_bucket_acl]
+relation: acl
+`), 0644))
+
+	config := configuration.NewInMemory()
+	config.Set(flagAnswers, path)
+	config.Set(flagSeverity, "high")
+	config.Set(flagControl, []string{"CIS-AWS:v1.4.0:2.1.5"})
+	answers, err := answersFromConfig(config)
+	require.NoError(t, err)
+
+	choice, err := answers.typeChoice()
+	require.NoError(t, err)
+	assert.Equal(t, TypeChoice(TypeRule), choice)
+	fields := answers.ruleFields()
+	assert.Equal(t, forms.RuleFields{
+		RuleID:        "ACME_001",
+		Title:         "Bucket is public",
+		Severity:      "high",
+		Description:   "Public buckets are bad",
+		Product:       []string{"iac", "cloud"},
+		InputType:     "tf",
+		ResourceTypes: []string{"aws_s3_bucket", "aws_s3_bucket_acl"},
+		Relation:      "acl",
+		Compliance: &forms.ComplianceFields{
+			Controls: []string{"CIS-AWS:v1.4.0:2.1.5"},
+		},
+	}, fields)
+	assert.Empty(t, missingRuleFields(fields))
+}
+
+func TestMissingRuleFields(t *testing.T) {
+	fields := Answers{
+		ID:            "ACME_001",
+		Product:       []string{"cloud"},
+		ResourceTypes: []string{"aws_s3_bucket", "aws_s3_bucket_acl"},
+	}.ruleFields()
+	assert.Equal(t, input.CloudScan.Name, fields.InputType)
+	assert.ElementsMatch(t, []string{flagTitle, flagSeverity, flagDescription, flagRelation}, missingRuleFields(fields))
+
+	fields.ResourceTypes = append(fields.ResourceTypes, "aws_s3_bucket_policy")
+	fields.Relation = "acl"
+	assert.ElementsMatch(t, []string{flagTitle, flagSeverity, flagDescription, flagLink}, missingRuleFields(fields))
+}
+
+func TestAnswersTypeChoice(t *testing.T) {
+	choice, err := Answers{Type: "spec"}.typeChoice()
+	require.NoError(t, err)
+	assert.Equal(t, TypeChoice(TypeSpec), choice)
+	_, err = Answers{Type: "module"}.typeChoice()
+	assert.Error(t, err)
+}
+
+func TestAnswersFromConfigArgs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		flagType      string
+		args          []string
+		expected      TypeChoice
+		expectedError bool
+	}{
+		{
+			name:     "argument",
+			args:     []string{"rule"},
+			expected: TypeRule,
+		},
+		{
+			name:     "flag",
+			flagType: "relation",
+			expected: TypeRelation,
+		},
+		{
+			name:     "argument and matching flag",
+			flagType: "Spec",
+			args:     []string{"spec"},
+			expected: TypeSpec,
+		},
+		{
+			name:          "argument and different flag",
+			flagType:      "rule",
+			args:          []string{"spec"},
+			expectedError: true,
+		},
+		{
+			name:          "several arguments",
+			args:          []string{"rule", "spec"},
+			expectedError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configuration.NewInMemory()
+			if tc.flagType != "" {
+				config.Set(flagType, tc.flagType)
+			}
+			if tc.args != nil {
+				config.Set(configuration.INPUT_DIRECTORY, tc.args)
+			}
+			answers, err := answersFromConfig(config)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			choice, err := answers.typeChoice()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, choice)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/erikgeiser/promptkit/textinput"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/init/forms"
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+const flagCatalog = "catalog"
+
+// CatalogWorkflow scaffolds every rule in a CSV or YAML catalog.
+func CatalogWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	logger := ictx.GetEnhancedLogger()
+	path := ictx.GetConfiguration().GetString(flagCatalog)
+	if path == "" {
+		if !interactive() {
+			return nil, fmt.Errorf("no TTY is available to prompt for the catalog, pass --%s", flagCatalog)
+		}
+		prompt := textinput.New("Catalog file (CSV or YAML):")
+		var err error
+		path, err = prompt.RunPrompt()
+		if err != nil {
+			return nil, err
+		}
+	}
+	rules, err := loadCatalog(path)
+	if err != nil {
+		return nil, err
+	}
+	proj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
+	checkProject(proj, logger)
+	templates, err := forms.LoadTemplates(proj)
+	if err != nil {
+		return nil, err
+	}
+	form := &forms.CatalogForm{
+		Project:   proj,
+		Templates: templates,
+		Resources: forms.LoadResourceCatalog(proj),
+		Rules:     rules,
+		Logger:    logger,
+	}
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+	if err := writeChanges(ictx, proj); err != nil {
+		return nil, err
+	}
+	return []workflow.Data{}, nil
+}
+
+// loadCatalog reads the rules from a catalog file. CSV catalogs have a header
+// row with the same column names as the keys of a YAML catalog, and separate
+// multiple products or resource types with semicolons.
+func loadCatalog(path string) ([]forms.RuleFields, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Answers
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = parseCSVCatalog(b)
+	default:
+		err = yaml.Unmarshal(b, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse catalog %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("catalog %s contains no rules", path)
+	}
+	rules := make([]forms.RuleFields, len(entries))
+	for i, e := range entries {
+		rules[i] = e.ruleFields()
+	}
+	return rules, nil
+}
+
+func parseCSVCatalog(b []byte) ([]Answers, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Answers
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var entry Answers
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+			switch strings.TrimSpace(strings.ToLower(column)) {
+			case "id":
+				entry.ID = value
+			case "title":
+				entry.Title = value
+			case "severity":
+				entry.Severity = value
+			case "description":
+				entry.Description = value
+			case "product":
+				entry.Product = splitList(value)
+			case "input_type":
+				entry.InputType = value
+			case "resource_types":
+				entry.ResourceTypes = splitList(value)
+			case "relation":
+				entry.Relation = value
+			case "links":
+				entry.Links = splitList(value)
+			case "template":
+				entry.Template = value
+			case "remediation":
+				entry.Remediation = value
+			case "references":
+				entry.References = splitList(value)
+			case "controls":
+				entry.Controls = splitList(value)
+			case "rego_tests":
+				if value != "" {
+					regoTests, err := strconv.ParseBool(value)
+					if err != nil {
+						return nil, fmt.Errorf("invalid rego_tests value '%s'", value)
+					}
+					entry.RegoTests = &regoTests
+				}
+			default:
+				return nil, fmt.Errorf("unknown column '%s'", column)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,115 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/init/forms"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	expected := []forms.RuleFields{
+		{
+			RuleID:        "ACME_001",
+			Title:         "Bucket is public",
+			Severity:      "high",
+			Description:   "Public buckets are bad",
+			Product:       []string{"iac"},
+			InputType:     "tf",
+			ResourceTypes: []string{"aws_s3_bucket"},
+		},
+		{
+			RuleID:        "ACME_002",
+			Title:         "Bucket has no ACL",
+			Severity:      "low",
+			Description:   "Buckets need ACLs",
+			Product:       []string{"iac", "cloud"},
+			InputType:     "tf",
+			ResourceTypes: []string{"aws_s3_bucket", "aws_s3_bucket_acl"},
+			Relation:      "acl",
+			Compliance: &forms.ComplianceFields{
+				Remediation: "Add an ACL",
+				References:  []string{"https://example.com/acls", "https://example.com/buckets"},
+				Controls:    []string{"CIS-AWS:v1.4.0:2.1.5"},
+			},
+		},
+	}
+	testCases := []struct {
+		name     string
+		file     string
+		contents string
+	}{
+		{
+			name: "csv",
+			file: "catalog.csv",
+			contents: `id,title,severity,description,product,input_type,resource_types,relation,remediation,references,controls
+ACME_001,Bucket is public,high,Public buckets are bad,iac,tf,aws_s3_bucket,,,,
+ACME_002,Bucket has no ACL,low,Buckets need ACLs,both,tf,aws_s3_bucket; aws_s3_bucket_acl,acl,Add an ACL,https://example.com/acls;https://example.com/buckets,CIS-AWS:v1.4.0:2.1.5
+`,
+		},
+		{
+			name: "yaml",
+			file: "catalog.yaml",
+			contents: `
+- id: ACME_001
+  title: Bucket is public
+  severity: high
+  description: Public buckets are bad
+  product: [iac]
+  input_type: tf
+  resource_types: [aws_s3_bucket]
+- id: ACME_002
+  title: Bucket has no ACL
+  severity: low
+  description: Buckets need ACLs
+  product: [both]
+  input_type: tf
+  resource_types: [aws_s3_bucket, aws_s3_bucket_acl]
+  relation: acl
+  remediation: Add an ACL
+  references: [https://example.com/acls, https://example.com/buckets]
+  controls: [CIS-AWS:v1.4.0:2.1.5]
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			require.NoError(t, os.WriteFile(path, []byte(tc.contents), 0644))
+			rules, err := loadCatalog(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rules)
+		})
+	}
+}
+
+func TestLoadCatalogErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "unknown.csv")
+	require.NoError(t, os.WriteFile(unknown, []byte("id,owner\nACME_001,me\n"), 0644))
+	empty := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(empty, []byte("[]\n"), 0644))
+
+	_, err := loadCatalog(unknown)
+	assert.ErrorContains(t, err, "unknown column 'owner'")
+	_, err = loadCatalog(empty)
+	assert.ErrorContains(t, err, "contains no rules")
+}
@@ -0,0 +1,164 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/policy-engine/pkg/input"
+)
+
+const catalogSpecName = "infra"
+
+// CatalogForm scaffolds a rule and a spec stub for every entry of a rule
+// catalog. All entries are validated before any rule is added to the project.
+type CatalogForm struct {
+	Project   *project.Project
+	Templates *Templates
+	Resources *ResourceCatalog
+	Rules     []RuleFields
+	Logger    *zerolog.Logger
+}
+
+func (f *CatalogForm) Run() error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	for _, fields := range f.Rules {
+		form, err := ruleSubForm(f.Project, fields, len(fields.ResourceTypes) > 1, f.Templates, f.Resources, f.Logger)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", fields.RuleID, err)
+		}
+		if err := form.Run(); err != nil {
+			return fmt.Errorf("rule %s: %w", fields.RuleID, err)
+		}
+		if fields.InputType == input.CloudScan.Name {
+			// Cloud specs are fetched from the API, so there is no stub to
+			// write.
+			f.Logger.Warn().Msgf("Skipping rule spec stub for %s, use init to fetch a cloud spec", fields.RuleID)
+			continue
+		}
+		filename, contents, err := f.Templates.spec(fields.InputType, DefaultTemplate, catalogSpecName)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", fields.RuleID, err)
+		}
+		path, err := f.Project.AddRuleSpec(fields.RuleID, filename, contents)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", fields.RuleID, err)
+		}
+		f.Logger.Info().Msgf("Writing rule spec stub to %s", path)
+	}
+	f.Logger.Info().Msgf("Scaffolded %d rules", len(f.Rules))
+	return nil
+}
+
+// validate checks every entry and reports all problems at once. Rule IDs must
+// not conflict with the project or with other entries.
+func (f *CatalogForm) validate() error {
+	var existingIDs []string
+	metadata, err := f.Project.RuleMetadata()
+	if err == nil {
+		for id := range metadata {
+			existingIDs = append(existingIDs, id)
+		}
+	}
+	existingDirs := f.Project.ListRules()
+
+	// Different IDs can map to the same rule directory, e.g. ACME-1 and
+	// ACME_1, so we also check for conflicts between directories.
+	dirs := map[string]string{}
+	for _, dir := range existingDirs {
+		dirs[dir] = "an existing rule"
+	}
+	var problems []string
+	for i, fields := range f.Rules {
+		errs := validateRuleFields(fields, existingIDs, existingDirs)
+		if fields.Template != "" {
+			names := f.Templates.RuleTemplateNames(len(fields.ResourceTypes) > 1)
+			if err := choiceValidator("rule template", names)(fields.Template); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if dir, err := project.SafePackageName(fields.RuleID); err == nil && len(errs) == 0 {
+			if other, ok := dirs[dir]; ok {
+				errs = append(errs, fmt.Errorf("rule directory %s conflicts with %s", dir, other))
+			}
+			dirs[dir] = fmt.Sprintf("entry %d (%s)", i+1, fields.RuleID)
+		}
+		for _, err := range errs {
+			problems = append(problems, fmt.Sprintf("entry %d (%s): %s", i+1, fields.RuleID, err))
+		}
+		existingIDs = append(existingIDs, fields.RuleID)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid rule catalog:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func validateRuleFields(fields RuleFields, existingIDs []string, existingDirs []string) []error {
+	var errs []error
+	if err := ruleIDValidator(existingIDs, existingDirs)(fields.RuleID); err != nil {
+		if fields.RuleID == "" {
+			err = fmt.Errorf("rule ID is missing")
+		}
+		errs = append(errs, err)
+	}
+	if fields.Title == "" {
+		errs = append(errs, fmt.Errorf("title is missing"))
+	}
+	if err := choiceValidator("severity", severities)(fields.Severity); err != nil {
+		errs = append(errs, err)
+	}
+	if len(fields.Product) == 0 {
+		errs = append(errs, fmt.Errorf("product is missing"))
+	}
+	for _, p := range fields.Product {
+		if err := choiceValidator("product", []string{"iac", "cloud"})(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	inputTypes := allInputTypes()
+	if len(fields.Product) == 1 && fields.Product[0] == "iac" {
+		inputTypes = iacInputTypes()
+	}
+	if err := choiceValidator("input type", inputTypes)(fields.InputType); err != nil {
+		errs = append(errs, err)
+	}
+	if fields.Compliance != nil {
+		if err := fields.Compliance.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(fields.ResourceTypes) {
+	case 0:
+		errs = append(errs, fmt.Errorf("expected at least one resource type"))
+	case 1:
+		if len(fields.Links) > 0 {
+			errs = append(errs, fmt.Errorf("links need at least three resource types"))
+		}
+	default:
+		if fields.Relation == "" {
+			errs = append(errs, fmt.Errorf("relation is required for rules with more than one resource type"))
+		}
+		if _, err := ParseResourceLinks(fields.ResourceTypes, fields.Links); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
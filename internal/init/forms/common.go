@@ -14,7 +14,11 @@
 
 package forms
 
-import "github.com/snyk/policy-engine/pkg/input"
+import (
+	"github.com/rs/zerolog"
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/policy-engine/pkg/input"
+)
 
 type Form interface {
 	Run() error
@@ -38,3 +42,16 @@ func iacInputTypes() []string {
 		input.Arm.Name,
 	}
 }
+
+// regoTestsFileName is the name of the rego test file that is generated along
+// with a rule.
+const regoTestsFileName = "main_test.rego"
+
+func addRegoTests(proj *project.Project, ruleID string, contents []byte, logger *zerolog.Logger) error {
+	path, err := proj.AddRuleFile(ruleID, regoTestsFileName, contents)
+	if err != nil {
+		return err
+	}
+	logger.Info().Msgf("Writing rego tests to %s", path)
+	return nil
+}
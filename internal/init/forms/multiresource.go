@@ -20,6 +20,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/erikgeiser/promptkit/confirmation"
 	"github.com/erikgeiser/promptkit/selection"
 	"github.com/erikgeiser/promptkit/textinput"
 	pluralize "github.com/gertd/go-pluralize"
@@ -33,6 +34,18 @@ type (
 		PrimaryResourceType   string
 		SecondaryResourceType string
 		Relation              string
+		// Links relate further resource types to the ones before them, which
+		// allows for chains such as bucket, policy and role, and for fan-outs
+		// such as a security group with instances and load balancers.
+		Links []ResourceLink
+	}
+
+	// ResourceLink relates a resource type to a resource type that comes
+	// before it in a multi-resource rule.
+	ResourceLink struct {
+		From         string
+		ResourceType string
+		Relation     string
 	}
 
 	MultiResourceRuleForm struct {
@@ -40,12 +53,22 @@ type (
 		RuleID    string
 		InputType string
 		Metadata  *project.RuleMetadata
+		Templates *Templates
+		Resources *ResourceCatalog
+		// Template is the name of the rule template, or empty for the default
+		// template.
+		Template string
+		// RegoTests adds a main_test.rego file with example tests.
+		RegoTests bool
 		Fields    MultiResourceRuleFields
 		Logger    *zerolog.Logger
 	}
 )
 
 func (f *MultiResourceRuleForm) Run() error {
+	// Further resource types are only prompted for when the resource types
+	// weren't given up front.
+	prepopulated := f.Fields.SecondaryResourceType != ""
 	if err := f.promptPrimaryResourceType(); err != nil {
 		return err
 	}
@@ -55,21 +78,22 @@ func (f *MultiResourceRuleForm) Run() error {
 	if err := f.promptRelation(); err != nil {
 		return err
 	}
-
-	pluralizer := pluralize.NewClient()
-	primarySingular, primaryPlural := toSingularAndPlural(pluralizer, f.InputType, f.Fields.PrimaryResourceType)
-	if isReserved(primarySingular) || isReserved(primaryPlural) {
-		// Sensible fallbacks for illegal or conflicting variable names
-		primaryPlural = "primary_resources"
-		primarySingular = "primary"
+	if !prepopulated {
+		if err := f.promptLinks(); err != nil {
+			return err
+		}
 	}
-	secondarySingular, secondaryPlural := toSingularAndPlural(pluralizer, f.InputType, f.Fields.SecondaryResourceType)
-	if isReserved(secondarySingular) ||
-		isReserved(secondaryPlural) ||
-		secondarySingular == primarySingular ||
-		secondaryPlural == primaryPlural {
-		secondarySingular = "secondary_resources"
-		secondaryPlural = "secondary"
+	for _, link := range f.Fields.Links {
+		warnUnknownResourceType(f.Resources, f.InputType, link.ResourceType, f.Logger)
+	}
+
+	resourceTypes := f.resourceTypes()
+	names := resourceVarNames(pluralize.NewClient(), f.InputType, resourceTypes)
+	primarySingular, primaryPlural := names[0].singular, names[0].plural
+	secondarySingular, secondaryPlural := names[1].singular, names[1].plural
+	additional, err := additionalResourceParams(resourceTypes, names, f.Fields)
+	if err != nil {
+		return err
 	}
 	metadataJSON, err := json.MarshalIndent(f.Metadata, "", "\t")
 	if err != nil {
@@ -79,17 +103,22 @@ func (f *MultiResourceRuleForm) Run() error {
 	if err != nil {
 		return err
 	}
-	rule, err := templateMultiResourceRule(multiResourceRuleParams{
+	params := multiResourceRuleParams{
 		RulePackage:               rulePackage,
 		InputType:                 f.InputType,
 		RuleMetadata:              string(metadataJSON),
+		MissingMetadata:           strings.Join(f.Metadata.MissingAuditFields(), ", "),
 		PrimaryResourceType:       f.Fields.PrimaryResourceType,
 		PrimaryResourceSingular:   primarySingular,
 		PrimaryResourcePlural:     primaryPlural,
+		SecondaryResourceType:     f.Fields.SecondaryResourceType,
 		SecondaryResourceSingular: secondarySingular,
 		SecondaryResourcePlural:   secondaryPlural,
 		Relation:                  f.Fields.Relation,
-	})
+		AdditionalResources:       additional,
+		MockRelations:             mockRelations(resourceTypes, names, f.Fields),
+	}
+	rule, err := f.Templates.multiResourceRule(f.Template, params)
 	if err != nil {
 		return err
 	}
@@ -98,15 +127,24 @@ func (f *MultiResourceRuleForm) Run() error {
 		return err
 	}
 	f.Logger.Info().Msgf("Writing rule to %s", path)
-	return nil
+
+	if !f.RegoTests {
+		return nil
+	}
+	test, err := f.Templates.multiResourceRuleTest(f.Template, params)
+	if err != nil {
+		return err
+	}
+	return addRegoTests(f.Project, f.RuleID, test, f.Logger)
 }
 
 func (f *MultiResourceRuleForm) promptPrimaryResourceType() error {
 	if f.Fields.PrimaryResourceType != "" {
+		warnUnknownResourceType(f.Resources, f.InputType, f.Fields.PrimaryResourceType, f.Logger)
 		return nil
 	}
 
-	prompt := textinput.New("Primary resource type:")
+	prompt := resourceTypePrompt("Primary resource type:", f.Resources, f.InputType)
 	primary, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -118,10 +156,11 @@ func (f *MultiResourceRuleForm) promptPrimaryResourceType() error {
 
 func (f *MultiResourceRuleForm) promptSecondaryResourceType() error {
 	if f.Fields.SecondaryResourceType != "" {
+		warnUnknownResourceType(f.Resources, f.InputType, f.Fields.SecondaryResourceType, f.Logger)
 		return nil
 	}
 
-	prompt := textinput.New("Secondary resource type:")
+	prompt := resourceTypePrompt("Secondary resource type:", f.Resources, f.InputType)
 	secondary, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -136,6 +175,65 @@ func (f *MultiResourceRuleForm) promptRelation() error {
 		return nil
 	}
 
+	relation, err := f.relationPrompt(f.Fields.PrimaryResourceType, f.Fields.SecondaryResourceType)
+	if err != nil {
+		return err
+	}
+	f.Fields.Relation = relation
+	return nil
+}
+
+// promptLinks prompts for further resource types until the user is done.
+func (f *MultiResourceRuleForm) promptLinks() error {
+	for {
+		prompt := confirmation.New("Does this rule need another resource type?", confirmation.No)
+		another, err := prompt.RunPrompt()
+		if err != nil {
+			return err
+		}
+		if !another {
+			return nil
+		}
+
+		from := f.Fields.PrimaryResourceType
+		existing := uniqueStrings(f.resourceTypes())
+		if len(existing) > 1 {
+			fromPrompt := selection.New("Which resource type is it related to?", existing)
+			from, err = fromPrompt.RunPrompt()
+			if err != nil {
+				return err
+			}
+		}
+		typePrompt := resourceTypePrompt("Resource type:", f.Resources, f.InputType)
+		resourceType, err := typePrompt.RunPrompt()
+		if err != nil {
+			return err
+		}
+		relation, err := f.relationPrompt(from, resourceType)
+		if err != nil {
+			return err
+		}
+		f.Fields.Links = append(f.Fields.Links, ResourceLink{
+			From:         from,
+			ResourceType: resourceType,
+			Relation:     relation,
+		})
+	}
+}
+
+// resourceTypes returns the resource types of the rule in order, starting
+// with the primary resource type.
+func (f *MultiResourceRuleForm) resourceTypes() []string {
+	resourceTypes := []string{f.Fields.PrimaryResourceType, f.Fields.SecondaryResourceType}
+	for _, link := range f.Fields.Links {
+		resourceTypes = append(resourceTypes, link.ResourceType)
+	}
+	return resourceTypes
+}
+
+// relationPrompt prompts for the relation between two resource types. A new
+// relation can be added to the project along the way.
+func (f *MultiResourceRuleForm) relationPrompt(primary string, secondary string) (string, error) {
 	const addNewRelation = "Add a new relation"
 	const enterManually = "Enter manually"
 	choices := []string{addNewRelation, enterManually}
@@ -146,37 +244,202 @@ func (f *MultiResourceRuleForm) promptRelation() error {
 		// relation manually.
 		relations = []string{}
 	}
-	prompt := selection.New("Choose a relation:", append(relations, choices...))
+	prompt := selection.New(fmt.Sprintf("Choose a relation from %s to %s:", primary, secondary), append(relations, choices...))
 	choice, err := prompt.RunPrompt()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	switch choice {
 	case addNewRelation:
 		form := &RelationForm{
-			Project: f.Project,
-			Logger:  f.Logger,
+			Project:   f.Project,
+			Templates: f.Templates,
+			Resources: f.Resources,
+			InputType: f.InputType,
+			Logger:    f.Logger,
 			Fields: RelationFields{
-				PrimaryResourceType:   f.Fields.PrimaryResourceType,
-				SecondaryResourceType: f.Fields.SecondaryResourceType,
+				PrimaryResourceType:   primary,
+				SecondaryResourceType: secondary,
 			},
 		}
 		if err := form.Run(); err != nil {
-			return err
+			return "", err
 		}
-		f.Fields.Relation = form.Fields.Name
+		return form.Fields.Name, nil
 	case enterManually:
 		prompt := textinput.New("Relation name:")
-		relation, err := prompt.RunPrompt()
-		if err != nil {
-			return err
-		}
-		f.Fields.Relation = relation
+		return prompt.RunPrompt()
 	default:
-		f.Fields.Relation = choice
+		return choice, nil
 	}
-	return nil
+}
+
+// ParseResourceLinks parses the links of the resource types after the
+// secondary one. A link is either a relation, which relates the resource type
+// to the one before it, or <from resource type>=<relation>.
+func ParseResourceLinks(resourceTypes []string, links []string) ([]ResourceLink, error) {
+	if len(resourceTypes) < 2 {
+		if len(links) > 0 {
+			return nil, fmt.Errorf("links need at least three resource types")
+		}
+		return nil, nil
+	}
+	additional := resourceTypes[2:]
+	if len(links) != len(additional) {
+		return nil, fmt.Errorf("expected a link for each resource type after the second one, got %d links for %d resource types", len(links), len(additional))
+	}
+	var parsed []ResourceLink
+	for i, link := range links {
+		from, relation, ok := strings.Cut(link, "=")
+		if !ok {
+			from, relation = resourceTypes[i+1], link
+		}
+		from, relation = strings.TrimSpace(from), strings.TrimSpace(relation)
+		if relation == "" {
+			return nil, fmt.Errorf("invalid link '%s', the relation is missing", link)
+		}
+		var found bool
+		for _, rt := range resourceTypes[:i+2] {
+			found = found || rt == from
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid link '%s', %s is not one of the resource types before %s", link, from, additional[i])
+		}
+		parsed = append(parsed, ResourceLink{
+			From:         from,
+			ResourceType: additional[i],
+			Relation:     relation,
+		})
+	}
+	return parsed, nil
+}
+
+// resourceVarName is a pair of rego variable names for a resource type.
+type resourceVarName struct {
+	singular string
+	plural   string
+}
+
+// resourceVarNames returns unique variable names for the resource types of a
+// rule, in the same order.
+func resourceVarNames(client *pluralize.Client, inputType string, resourceTypes []string) []resourceVarName {
+	used := map[string]bool{}
+	names := make([]resourceVarName, len(resourceTypes))
+	for i, resourceType := range resourceTypes {
+		singular, plural := toSingularAndPlural(client, inputType, resourceType)
+		if isReserved(singular) ||
+			isReserved(plural) ||
+			singular == plural ||
+			used[singular] ||
+			used[plural] {
+			// Sensible fallbacks for illegal or conflicting variable names
+			singular, plural = fallbackVarNames(i)
+		}
+		used[singular] = true
+		used[plural] = true
+		names[i] = resourceVarName{singular: singular, plural: plural}
+	}
+	return names
+}
+
+func fallbackVarNames(i int) (string, string) {
+	switch i {
+	case 0:
+		return "primary", "primary_resources"
+	case 1:
+		return "secondary", "secondary_resources"
+	}
+	return fmt.Sprintf("related_%d", i), fmt.Sprintf("related_%d_resources", i)
+}
+
+// additionalResourceParams returns the template parameters of the resource
+// types after the secondary one. A resource type is related to the first
+// resource type before it with the type of its link.
+func additionalResourceParams(resourceTypes []string, names []resourceVarName, fields MultiResourceRuleFields) ([]relatedResourceParams, error) {
+	related := make([]relatedResourceParams, len(resourceTypes))
+	related[1] = relatedResourceParams{
+		ResourceType: resourceTypes[1],
+		Singular:     names[1].singular,
+		Plural:       names[1].plural,
+		Relation:     fields.Relation,
+		Parent:       names[0].singular,
+		ParentPlural: names[0].plural,
+	}
+	parents := make([]int, len(resourceTypes))
+	var additional []relatedResourceParams
+	for i, link := range fields.Links {
+		index := i + 2
+		parent := -1
+		for j := 0; j < index; j++ {
+			if resourceTypes[j] == link.From {
+				parent = j
+				break
+			}
+		}
+		if parent < 0 {
+			return nil, fmt.Errorf("resource type %s is not part of the rule", link.From)
+		}
+		parents[index] = parent
+		r := relatedResourceParams{
+			ResourceType: link.ResourceType,
+			Singular:     names[index].singular,
+			Plural:       names[index].plural,
+			Relation:     link.Relation,
+			Parent:       names[parent].singular,
+			ParentPlural: names[parent].plural,
+		}
+		for p := parent; p != 0; p = parents[p] {
+			r.Path = append([]relatedResourceParams{related[p]}, r.Path...)
+		}
+		related[index] = r
+		additional = append(additional, r)
+	}
+	return additional, nil
+}
+
+// mockRelations returns the relations of a rule to mock in its rego tests, in
+// the order of the links.
+func mockRelations(resourceTypes []string, names []resourceVarName, fields MultiResourceRuleFields) []mockRelationParams {
+	mocks := []mockRelationParams{{
+		ResourceType: resourceTypes[0],
+		Relation:     fields.Relation,
+		Valid:        []string{"valid_" + names[1].singular},
+		Invalid:      []string{"invalid_" + names[1].singular},
+	}}
+	for i, link := range fields.Links {
+		mock := "mock_" + names[i+2].singular
+		found := false
+		for j := range mocks {
+			if mocks[j].ResourceType == link.From && mocks[j].Relation == link.Relation {
+				mocks[j].Valid = append(mocks[j].Valid, mock)
+				mocks[j].Invalid = append(mocks[j].Invalid, mock)
+				found = true
+				break
+			}
+		}
+		if !found {
+			mocks = append(mocks, mockRelationParams{
+				ResourceType: link.From,
+				Relation:     link.Relation,
+				Valid:        []string{mock},
+				Invalid:      []string{mock},
+			})
+		}
+	}
+	return mocks
+}
+
+func uniqueStrings(values []string) []string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
 }
 
 var camelPat = regexp.MustCompile(`(.)([A-Z])`)
@@ -0,0 +1,249 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gertd/go-pluralize"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResourceLinks(t *testing.T) {
+	resourceTypes := []string{"aws_s3_bucket", "aws_s3_bucket_policy", "aws_iam_role", "aws_kms_key"}
+	testCases := []struct {
+		name          string
+		resourceTypes []string
+		links         []string
+		expected      []ResourceLink
+		expectedError string
+	}{
+		{
+			name:          "two resource types",
+			resourceTypes: resourceTypes[:2],
+		},
+		{
+			name:          "chain",
+			resourceTypes: resourceTypes,
+			links:         []string{"policy_role", "role_key"},
+			expected: []ResourceLink{
+				{From: "aws_s3_bucket_policy", ResourceType: "aws_iam_role", Relation: "policy_role"},
+				{From: "aws_iam_role", ResourceType: "aws_kms_key", Relation: "role_key"},
+			},
+		},
+		{
+			name:          "fan-out",
+			resourceTypes: resourceTypes,
+			links:         []string{"policy_role", "aws_s3_bucket = bucket_key"},
+			expected: []ResourceLink{
+				{From: "aws_s3_bucket_policy", ResourceType: "aws_iam_role", Relation: "policy_role"},
+				{From: "aws_s3_bucket", ResourceType: "aws_kms_key", Relation: "bucket_key"},
+			},
+		},
+		{
+			name:          "missing link",
+			resourceTypes: resourceTypes,
+			links:         []string{"policy_role"},
+			expectedError: "got 1 links for 2 resource types",
+		},
+		{
+			name:          "links without additional resource types",
+			resourceTypes: resourceTypes[:1],
+			links:         []string{"policy_role"},
+			expectedError: "links need at least three resource types",
+		},
+		{
+			name:          "unknown from resource type",
+			resourceTypes: resourceTypes[:3],
+			links:         []string{"aws_kms_key=key_role"},
+			expectedError: "aws_kms_key is not one of the resource types before aws_iam_role",
+		},
+		{
+			name:          "missing relation",
+			resourceTypes: resourceTypes[:3],
+			links:         []string{"aws_s3_bucket="},
+			expectedError: "the relation is missing",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			links, err := ParseResourceLinks(tc.resourceTypes, tc.links)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, links)
+			}
+		})
+	}
+}
+
+func TestResourceVarNames(t *testing.T) {
+	names := resourceVarNames(pluralize.NewClient(), "tf", []string{
+		"aws_s3_bucket",
+		"aws_s3_bucket_policy",
+		"aws_iam_policy",
+		"aws_default_resource",
+		"aws_lb",
+	})
+	assert.Equal(t, []resourceVarName{
+		{singular: "bucket", plural: "buckets"},
+		{singular: "policy", plural: "policies"},
+		{singular: "related_2", plural: "related_2_resources"},
+		{singular: "related_3", plural: "related_3_resources"},
+		{singular: "lb", plural: "lbs"},
+	}, names)
+
+	names = resourceVarNames(pluralize.NewClient(), "tf", []string{"aws_resource", "aws_default_resource"})
+	assert.Equal(t, []resourceVarName{
+		{singular: "primary", plural: "primary_resources"},
+		{singular: "secondary", plural: "secondary_resources"},
+	}, names)
+}
+
+// snykStub declares the snyk functions that the rego tests of multi-resource
+// rules replace with mocks. Without mocks, there is a single resource without
+// any related resources.
+const snykStub = `package snyk
+
+resources(resource_type) := [{"id": "primary"}]
+
+relates(resource, relation) := []
+`
+
+func TestMultiResourceRuleTemplates(t *testing.T) {
+	resourceTypes := []string{"aws_security_group", "aws_instance", "aws_lb", "aws_ebs_volume"}
+	testCases := []struct {
+		name         string
+		links        []string
+		contains     []string
+		testContains []string
+	}{
+		{
+			name:  "chain",
+			links: []string{"instance_lb", "lb_volume"},
+			contains: []string{
+				"instances := snyk.relates(group, \"group_instance\")\n\tinstance := instances[_]\n\tlbs := snyk.relates(instance, \"instance_lb\")\n\tlb := lbs[_]\n\tvolumes := snyk.relates(lb, \"lb_volume\")\n",
+				"\"resource\": volumes[_]",
+			},
+			testContains: []string{
+				"[mock_volume] {\n\tresource._type == \"aws_lb\"\n\trelation == \"lb_volume\"\n}",
+			},
+		},
+		{
+			name:  "fan-out",
+			links: []string{"aws_security_group=group_lb", "aws_instance=instance_volume"},
+			contains: []string{
+				"instances := snyk.relates(group, \"group_instance\")\n\tlbs := snyk.relates(group, \"group_lb\")\n\tvolumes := [volume | instance := instances[_]; volume := snyk.relates(instance, \"instance_volume\")[_]]\n",
+				"\"resource\": lbs[_]",
+			},
+			testContains: []string{
+				"[mock_volume] {\n\tresource._type == \"aws_instance\"\n\trelation == \"instance_volume\"\n}",
+			},
+		},
+		{
+			name:  "repeated relation",
+			links: []string{"aws_security_group=attached", "aws_lb=attached"},
+			testContains: []string{
+				"[mock_lb] {\n\tresource._type == \"aws_security_group\"\n\trelation == \"attached\"\n}",
+				"[mock_volume] {\n\tresource._type == \"aws_lb\"\n\trelation == \"attached\"\n}",
+			},
+		},
+		{
+			name:  "repeated relation from the same resource type",
+			links: []string{"aws_security_group=group_instance", "aws_lb=lb_volume"},
+			testContains: []string{
+				"[valid_instance, mock_lb] {\n\tresource._type == \"aws_security_group\"\n\trelation == \"group_instance\"\n}",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			links, err := ParseResourceLinks(resourceTypes, tc.links)
+			require.NoError(t, err)
+			fields := MultiResourceRuleFields{
+				PrimaryResourceType:   resourceTypes[0],
+				SecondaryResourceType: resourceTypes[1],
+				Relation:              "group_instance",
+				Links:                 links,
+			}
+			names := resourceVarNames(pluralize.NewClient(), "tf", resourceTypes)
+			additional, err := additionalResourceParams(resourceTypes, names, fields)
+			require.NoError(t, err)
+			params := multiResourceRuleParams{
+				RulePackage:               "ACME_001",
+				InputType:                 "tf",
+				RuleMetadata:              `{"id": "ACME-001"}`,
+				PrimaryResourceType:       resourceTypes[0],
+				PrimaryResourceSingular:   names[0].singular,
+				PrimaryResourcePlural:     names[0].plural,
+				SecondaryResourceType:     resourceTypes[1],
+				SecondaryResourceSingular: names[1].singular,
+				SecondaryResourcePlural:   names[1].plural,
+				Relation:                  fields.Relation,
+				AdditionalResources:       additional,
+				MockRelations:             mockRelations(resourceTypes, names, fields),
+			}
+
+			var templates *Templates
+			rule, err := templates.multiResourceRule("", params)
+			require.NoError(t, err)
+			module, err := ast.ParseModule("main.rego", string(rule))
+			require.NoError(t, err)
+			var resourcesRules int
+			for _, r := range module.Rules {
+				if r.Head.Name.Equal(ast.Var("resources")) {
+					resourcesRules++
+				}
+			}
+			assert.Equal(t, len(resourceTypes), resourcesRules)
+			for _, c := range tc.contains {
+				assert.Contains(t, string(rule), c)
+			}
+
+			test, err := templates.multiResourceRuleTest("", params)
+			require.NoError(t, err)
+			assert.Contains(t, string(test), "mock_lb := {")
+			for _, c := range tc.testContains {
+				assert.Contains(t, string(test), c)
+			}
+
+			// The generated rule has no conditions yet, so test_invalid passes
+			// as long as the mocks are consistent.
+			rs, err := rego.New(
+				rego.Query("data.rules.ACME_001.test_invalid"),
+				rego.Module("snyk.rego", snykStub),
+				rego.Module("main.rego", string(rule)),
+				rego.Module("main_test.rego", string(test)),
+			).Eval(context.Background())
+			require.NoError(t, err)
+			assert.True(t, rs.Allowed())
+
+			// deny only iterates over the primary resources, so it also fires
+			// when there are no related resources.
+			rs, err = rego.New(
+				rego.Query("count(data.rules.ACME_001.deny) == 1"),
+				rego.Module("snyk.rego", snykStub),
+				rego.Module("main.rego", string(rule)),
+			).Eval(context.Background())
+			require.NoError(t, err)
+			assert.True(t, rs.Allowed())
+		})
+	}
+}
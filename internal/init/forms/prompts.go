@@ -15,12 +15,15 @@
 package forms
 
 import (
+	"fmt"
+
 	"github.com/erikgeiser/promptkit/confirmation"
+	"github.com/erikgeiser/promptkit/selection"
 	"github.com/erikgeiser/promptkit/textinput"
 )
 
 type multiplePrompt struct {
-	prompt  *textinput.TextInput
+	prompt  Prompter[string]
 	another *confirmation.Confirmation
 }
 
@@ -65,3 +68,54 @@ func (p *optionalPrompt[T]) RunPrompt() (T, error) {
 	}
 	return value, nil
 }
+
+// knownValuePrompt offers a filterable list of known values, with the option
+// to enter a value that is not in the list. When a known function is set,
+// manually entered values that it doesn't recognize have to be confirmed.
+type knownValuePrompt struct {
+	label   string
+	choices []string
+	known   func(string) bool
+	kind    string
+}
+
+func (p *knownValuePrompt) RunPrompt() (string, error) {
+	for {
+		value, err := p.runChoice()
+		if err != nil {
+			return "", err
+		}
+		if p.known == nil || p.known(value) {
+			return value, nil
+		}
+		confirm := confirmation.New(
+			fmt.Sprintf("'%s' is not a known %s. Use it anyway?", value, p.kind),
+			confirmation.No,
+		)
+		ok, err := confirm.RunPrompt()
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return value, nil
+		}
+	}
+}
+
+func (p *knownValuePrompt) runChoice() (string, error) {
+	if len(p.choices) == 0 {
+		return textinput.New(p.label).RunPrompt()
+	}
+	const enterManually = "Enter manually"
+	prompt := selection.New(p.label, append(p.choices, enterManually))
+	prompt.FilterPrompt = "Type to filter:"
+	prompt.PageSize = 10
+	choice, err := prompt.RunPrompt()
+	if err != nil {
+		return "", err
+	}
+	if choice == enterManually {
+		return textinput.New(p.label).RunPrompt()
+	}
+	return choice, nil
+}
@@ -16,9 +16,13 @@ package forms
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/erikgeiser/promptkit/confirmation"
 	"github.com/erikgeiser/promptkit/textinput"
+	"github.com/gertd/go-pluralize"
 	"github.com/rs/zerolog"
 	"github.com/snyk/cli-extension-iac-rules/internal/project"
 )
@@ -30,12 +34,18 @@ type (
 		PrimaryAttributes     []string
 		SecondaryResourceType string
 		SecondaryAttributes   []string
+		Template              string
 	}
 
 	RelationForm struct {
-		Project *project.Project
-		Fields  RelationFields
-		Logger  *zerolog.Logger
+		Project   *project.Project
+		Templates *Templates
+		Resources *ResourceCatalog
+		// InputType narrows down the resource types and attributes that are
+		// offered. Relations themselves work across input types.
+		InputType string
+		Fields    RelationFields
+		Logger    *zerolog.Logger
 	}
 )
 
@@ -55,14 +65,24 @@ func (f *RelationForm) Run() error {
 	if err := f.promptSecondaryAttributes(); err != nil {
 		return err
 	}
+	if err := promptTemplate("relation", f.Templates.RelationTemplateNames(), &f.Fields.Template); err != nil {
+		return err
+	}
 
-	relation, err := templateRelation(relationParams{
-		Name:              f.Fields.Name,
-		LeftResourceType:  f.Fields.PrimaryResourceType,
-		LeftAttributes:    f.Fields.PrimaryAttributes,
-		RightResourceType: f.Fields.SecondaryResourceType,
-		RightAttributes:   f.Fields.SecondaryAttributes,
-	})
+	params, err := newRelationParams(f.Fields)
+	if err != nil {
+		return err
+	}
+	if f.Fields.Template == "" || f.Fields.Template == DefaultTemplate {
+		for _, attributes := range [][]string{f.Fields.PrimaryAttributes, f.Fields.SecondaryAttributes} {
+			for _, attribute := range attributes {
+				if strings.ContainsAny(attribute, ".[") {
+					f.Logger.Warn().Msgf("Attribute '%s' is nested, which needs the %s or %s relation template", attribute, KeysRelationTemplate, NestedRelationTemplate)
+				}
+			}
+		}
+	}
+	relation, err := f.Templates.relation(f.Fields.Template, params)
 	if err != nil {
 		return err
 	}
@@ -91,10 +111,11 @@ func (f *RelationForm) promptName() error {
 
 func (f *RelationForm) promptPrimaryResourceType() error {
 	if f.Fields.PrimaryResourceType != "" {
+		warnUnknownResourceType(f.Resources, f.InputType, f.Fields.PrimaryResourceType, f.Logger)
 		return nil
 	}
 
-	prompt := textinput.New("Primary resource type:")
+	prompt := resourceTypePrompt("Primary resource type:", f.Resources, f.InputType)
 	primary, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -109,7 +130,7 @@ func (f *RelationForm) promptPrimaryAttributes() error {
 		return nil
 	}
 
-	prompt := attrsPrompt(f.Fields.PrimaryResourceType)
+	prompt := f.attrsPrompt(f.Fields.PrimaryResourceType)
 	attrs, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -121,10 +142,11 @@ func (f *RelationForm) promptPrimaryAttributes() error {
 
 func (f *RelationForm) promptSecondaryResourceType() error {
 	if f.Fields.SecondaryResourceType != "" {
+		warnUnknownResourceType(f.Resources, f.InputType, f.Fields.SecondaryResourceType, f.Logger)
 		return nil
 	}
 
-	prompt := textinput.New("Secondary resource type:")
+	prompt := resourceTypePrompt("Secondary resource type:", f.Resources, f.InputType)
 	secondary, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -139,7 +161,7 @@ func (f *RelationForm) promptSecondaryAttributes() error {
 		return nil
 	}
 
-	prompt := attrsPrompt(f.Fields.SecondaryResourceType)
+	prompt := f.attrsPrompt(f.Fields.SecondaryResourceType)
 	attrs, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -149,9 +171,150 @@ func (f *RelationForm) promptSecondaryAttributes() error {
 	return nil
 }
 
-func attrsPrompt(resourceType string) *multiplePrompt {
+func (f *RelationForm) attrsPrompt(resourceType string) *multiplePrompt {
+	prompt := &knownValuePrompt{
+		label:   fmt.Sprintf("Attribute from %s:", resourceType),
+		choices: f.Resources.Attributes(f.InputType, resourceType),
+	}
 	return &multiplePrompt{
-		prompt:  textinput.New(fmt.Sprintf("Attribute from %s:", resourceType)),
+		prompt:  prompt,
 		another: confirmation.New("Would you like to add another attribute?", confirmation.No),
 	}
 }
+
+func newRelationParams(fields RelationFields) (relationParams, error) {
+	params := relationParams{
+		Name:              fields.Name,
+		LeftResourceType:  fields.PrimaryResourceType,
+		LeftAttributes:    fields.PrimaryAttributes,
+		RightResourceType: fields.SecondaryResourceType,
+		RightAttributes:   fields.SecondaryAttributes,
+	}
+	var err error
+	if params.LeftKey, err = attributesKey("left", fields.PrimaryAttributes); err != nil {
+		return params, err
+	}
+	if params.LeftPath, err = attributesPath("left", fields.PrimaryAttributes); err != nil {
+		return params, err
+	}
+	if params.RightKey, err = attributesKey("right", fields.SecondaryAttributes); err != nil {
+		return params, err
+	}
+	if params.RightPath, err = attributesPath("right", fields.SecondaryAttributes); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// attributeSegment is a part of an attribute path such as
+// ingress[].security_groups[], where [] marks a list.
+type attributeSegment struct {
+	name string
+	list bool
+}
+
+func parseAttribute(attribute string) ([]attributeSegment, error) {
+	var segments []attributeSegment
+	for _, part := range strings.Split(attribute, ".") {
+		name, list := strings.CutSuffix(part, "[]")
+		if name == "" || strings.ContainsAny(name, "[]") {
+			return nil, fmt.Errorf("invalid attribute '%s', expected a path such as ingress[].security_groups[]", attribute)
+		}
+		segments = append(segments, attributeSegment{name: name, list: list})
+	}
+	return segments, nil
+}
+
+// ref appends the segment to a rego reference.
+func (s attributeSegment) ref(ref string) string {
+	if identifierRegex.MatchString(s.name) {
+		return ref + "." + s.name
+	}
+	return fmt.Sprintf("%s[%s]", ref, strconv.Quote(s.name))
+}
+
+// attributesKey returns the rego expression of the given attributes of the
+// resource bound to root. Several attributes make up an array.
+func attributesKey(root string, attributes []string) (string, error) {
+	var keys []string
+	for _, attribute := range attributes {
+		segments, err := parseAttribute(attribute)
+		if err != nil {
+			return "", err
+		}
+		ref := root
+		for _, s := range segments {
+			ref = s.ref(ref)
+			if s.list {
+				ref += "[_]"
+			}
+		}
+		keys = append(keys, ref)
+	}
+	return joinKeys(keys), nil
+}
+
+// attributesPath is like attributesKey, but binds the nested blocks along the
+// way to variables. Attributes within the same block share its variable.
+func attributesPath(root string, attributes []string) (relationPath, error) {
+	var path relationPath
+	pluralizer := pluralize.NewClient()
+	used := map[string]bool{"left": true, "right": true, "key": true}
+	vars := map[string]string{}
+	var keys []string
+	for _, attribute := range attributes {
+		segments, err := parseAttribute(attribute)
+		if err != nil {
+			return path, err
+		}
+		ref := root
+		for i, s := range segments {
+			ref = s.ref(ref)
+			if !s.list {
+				continue
+			}
+			if i == len(segments)-1 {
+				ref += "[_]"
+				continue
+			}
+			v, ok := vars[ref]
+			if !ok {
+				v = blockVar(pluralizer.Singular(s.name), used)
+				vars[ref] = v
+				path.Blocks = append(path.Blocks, relationBlock{Var: v, Ref: ref})
+			}
+			ref = v
+		}
+		keys = append(keys, ref)
+	}
+	path.Key = joinKeys(keys)
+	return path, nil
+}
+
+// blockVar returns an unused variable name based on the given name.
+func blockVar(name string, used map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	if !identifierRegex.MatchString(base) || isReserved(base) {
+		base = "block_" + base
+	}
+	v := base
+	for i := 2; used[v]; i++ {
+		v = fmt.Sprintf("%s_%d", base, i)
+	}
+	used[v] = true
+	return v
+}
+
+func joinKeys(keys []string) string {
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	return "[" + strings.Join(keys, ", ") + "]"
+}
@@ -0,0 +1,82 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRelationParams(t *testing.T) {
+	params, err := newRelationParams(RelationFields{
+		Name:                  "instance_security_group",
+		PrimaryResourceType:   "aws_instance",
+		PrimaryAttributes:     []string{"network_interface[].security_groups[]", "network_interface[].subnet_id", "tags.team-name"},
+		SecondaryResourceType: "aws_security_group",
+		SecondaryAttributes:   []string{"id"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `[left.network_interface[_].security_groups[_], left.network_interface[_].subnet_id, left.tags["team-name"]]`, params.LeftKey)
+	assert.Equal(t, relationPath{
+		Blocks: []relationBlock{{Var: "network_interface", Ref: "left.network_interface"}},
+		Key:    `[network_interface.security_groups[_], network_interface.subnet_id, left.tags["team-name"]]`,
+	}, params.LeftPath)
+	assert.Equal(t, "right.id", params.RightKey)
+	assert.Equal(t, relationPath{Key: "right.id"}, params.RightPath)
+
+	_, err = newRelationParams(RelationFields{PrimaryAttributes: []string{"ingress[0].from_port"}})
+	assert.ErrorContains(t, err, "invalid attribute 'ingress[0].from_port'")
+}
+
+func TestAttributesPathBlockVars(t *testing.T) {
+	path, err := attributesPath("left", []string{"keys[].key[].value", "rules[].ports[]"})
+	require.NoError(t, err)
+	assert.Equal(t, relationPath{
+		Blocks: []relationBlock{
+			{Var: "key_2", Ref: "left.keys"},
+			{Var: "key_3", Ref: "key_2.key"},
+			{Var: "rule", Ref: "left.rules"},
+		},
+		Key: "[key_3.value, rule.ports[_]]",
+	}, path)
+}
+
+func TestRelationTemplates(t *testing.T) {
+	params, err := newRelationParams(RelationFields{
+		Name:                  "security_group_ingress",
+		PrimaryResourceType:   "aws_security_group",
+		PrimaryAttributes:     []string{"ingress[].security_groups[]"},
+		SecondaryResourceType: "aws_security_group",
+		SecondaryAttributes:   []string{"id"},
+	})
+	require.NoError(t, err)
+	var templates *Templates
+	for _, name := range templates.RelationTemplateNames() {
+		t.Run(name, func(t *testing.T) {
+			relation, err := templates.relation(name, params)
+			require.NoError(t, err)
+			_, err = ast.ParseRule(relation)
+			require.NoError(t, err)
+			assert.Contains(t, relation, `"security_group_ingress"`)
+		})
+	}
+
+	relation, err := templates.relation(NestedRelationTemplate, params)
+	require.NoError(t, err)
+	assert.Contains(t, relation, "ingress := left.ingress[_]\n\t\t\t\tkey := ingress.security_groups[_]\n")
+}
@@ -0,0 +1,210 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk/policy-engine/pkg/input"
+	"github.com/snyk/policy-engine/pkg/models"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+//go:embed resourcecatalog/*.json
+var resourceCatalogFS embed.FS
+
+// ResourceCatalog lists known resource types and their attributes by input
+// type. It is used to offer choices in the forms and to catch typos in
+// resource types. A nil *ResourceCatalog only contains the built-in catalog.
+type ResourceCatalog struct {
+	// types maps input types to resource types to attribute names.
+	types map[string]map[string]map[string]struct{}
+}
+
+// DefaultResourceCatalog returns the built-in catalog of common resource types
+// and attributes.
+func DefaultResourceCatalog() *ResourceCatalog {
+	c := &ResourceCatalog{types: map[string]map[string]map[string]struct{}{}}
+	entries, err := resourceCatalogFS.ReadDir("resourcecatalog")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		b, err := resourceCatalogFS.ReadFile(path.Join("resourcecatalog", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var resourceTypes map[string][]string
+		if err := json.Unmarshal(b, &resourceTypes); err != nil {
+			panic(err)
+		}
+		inputType := strings.TrimSuffix(entry.Name(), ".json")
+		for resourceType, attributes := range resourceTypes {
+			c.add(inputType, resourceType, attributes)
+		}
+	}
+	return c
+}
+
+// LoadResourceCatalog returns the built-in catalog extended with the resource
+// types and attributes found in the project's spec inputs. Specs that fail to
+// load are skipped.
+func LoadResourceCatalog(proj *project.Project) *ResourceCatalog {
+	c := DefaultResourceCatalog()
+	for _, spec := range proj.RuleSpecs() {
+		singleInput, err := utils.LoadSingleInput(spec.Input.Path())
+		if err != nil {
+			continue
+		}
+		c.AddState(singleInput.State)
+	}
+	return c
+}
+
+// AddState adds the resource types and top-level attributes of the given
+// state.
+func (c *ResourceCatalog) AddState(state models.State) {
+	inputType := catalogInputType(state.InputType)
+	for resourceType, resources := range state.Resources {
+		var attributes []string
+		for _, resource := range resources {
+			for attribute := range resource.Attributes {
+				attributes = append(attributes, attribute)
+			}
+		}
+		c.add(inputType, resourceType, attributes)
+	}
+}
+
+func (c *ResourceCatalog) add(inputType string, resourceType string, attributes []string) {
+	if c.types[inputType] == nil {
+		c.types[inputType] = map[string]map[string]struct{}{}
+	}
+	if c.types[inputType][resourceType] == nil {
+		c.types[inputType][resourceType] = map[string]struct{}{}
+	}
+	for _, attribute := range attributes {
+		c.types[inputType][resourceType][attribute] = struct{}{}
+	}
+}
+
+// ResourceTypes lists the known resource types for the given input type in
+// alphabetical order. An empty input type lists the resource types of all
+// input types.
+func (c *ResourceCatalog) ResourceTypes(inputType string) []string {
+	c = c.orDefault()
+	seen := map[string]struct{}{}
+	for _, t := range c.inputTypes(inputType) {
+		for resourceType := range c.types[t] {
+			seen[resourceType] = struct{}{}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// HasResourceType returns whether the resource type is known for the given
+// input type.
+func (c *ResourceCatalog) HasResourceType(inputType string, resourceType string) bool {
+	c = c.orDefault()
+	for _, t := range c.inputTypes(inputType) {
+		if _, ok := c.types[t][resourceType]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Attributes lists the known attributes of a resource type in alphabetical
+// order. An empty input type looks the resource type up in all input types.
+func (c *ResourceCatalog) Attributes(inputType string, resourceType string) []string {
+	c = c.orDefault()
+	seen := map[string]struct{}{}
+	for _, t := range c.inputTypes(inputType) {
+		for attribute := range c.types[t][resourceType] {
+			seen[attribute] = struct{}{}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+func (c *ResourceCatalog) inputTypes(inputType string) []string {
+	if inputType == "" {
+		var inputTypes []string
+		for t := range c.types {
+			inputTypes = append(inputTypes, t)
+		}
+		return inputTypes
+	}
+	inputType = catalogInputType(inputType)
+	if inputType == input.CloudScan.Name {
+		// Cloud resources use the same resource types as Terraform.
+		return []string{input.CloudScan.Name, input.Terraform.Name}
+	}
+	return []string{inputType}
+}
+
+func (c *ResourceCatalog) orDefault() *ResourceCatalog {
+	if c == nil {
+		return DefaultResourceCatalog()
+	}
+	return c
+}
+
+// catalogInputType maps the input types of states, e.g. tf_hcl or tf_plan, to
+// the input types of rules.
+func catalogInputType(inputType string) string {
+	if strings.HasPrefix(inputType, input.Terraform.Name) {
+		return input.Terraform.Name
+	}
+	return inputType
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func resourceTypePrompt(label string, catalog *ResourceCatalog, inputType string) *knownValuePrompt {
+	catalog = catalog.orDefault()
+	return &knownValuePrompt{
+		label:   label,
+		choices: catalog.ResourceTypes(inputType),
+		known: func(resourceType string) bool {
+			return catalog.HasResourceType(inputType, resourceType)
+		},
+		kind: strings.TrimSpace(inputType + " resource type"),
+	}
+}
+
+// warnUnknownResourceType warns about pre-populated resource types that are
+// not in the catalog. These are not rejected because the catalog is not
+// exhaustive.
+func warnUnknownResourceType(catalog *ResourceCatalog, inputType string, resourceType string, logger *zerolog.Logger) {
+	if !catalog.HasResourceType(inputType, resourceType) {
+		logger.Warn().Msgf("'%s' is not a known %s, check it for typos",
+			resourceType, strings.TrimSpace(inputType+" resource type"))
+	}
+}
@@ -0,0 +1,62 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"testing"
+
+	"github.com/snyk/policy-engine/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultResourceCatalog(t *testing.T) {
+	catalog := DefaultResourceCatalog()
+	for _, inputType := range []string{"tf", "cfn", "k8s", "arm", "cloud_scan"} {
+		assert.NotEmpty(t, catalog.ResourceTypes(inputType), inputType)
+	}
+	assert.True(t, catalog.HasResourceType("tf", "aws_s3_bucket"))
+	assert.False(t, catalog.HasResourceType("tf", "aws_s3_buckett"))
+	assert.False(t, catalog.HasResourceType("cfn", "aws_s3_bucket"))
+	assert.True(t, catalog.HasResourceType("cloud_scan", "aws_s3_bucket"))
+	assert.True(t, catalog.HasResourceType("", "AWS::S3::Bucket"))
+	assert.Contains(t, catalog.Attributes("tf", "aws_s3_bucket"), "bucket")
+	assert.Contains(t, catalog.Attributes("", "Deployment"), "spec")
+}
+
+func TestResourceCatalogAddState(t *testing.T) {
+	catalog := DefaultResourceCatalog()
+	catalog.AddState(models.State{
+		InputType: "tf_hcl",
+		Resources: map[string]map[string]models.ResourceState{
+			"acme_widget": {
+				"acme_widget.a": {Attributes: map[string]interface{}{"name": "a", "size": 1}},
+				"acme_widget.b": {Attributes: map[string]interface{}{"name": "b", "color": "red"}},
+			},
+			"aws_s3_bucket": {
+				"aws_s3_bucket.b": {Attributes: map[string]interface{}{"custom_attribute": true}},
+			},
+		},
+	})
+	assert.True(t, catalog.HasResourceType("tf", "acme_widget"))
+	assert.Equal(t, []string{"color", "name", "size"}, catalog.Attributes("tf", "acme_widget"))
+	assert.Contains(t, catalog.Attributes("tf", "aws_s3_bucket"), "custom_attribute")
+	assert.Contains(t, catalog.Attributes("tf", "aws_s3_bucket"), "bucket")
+}
+
+func TestNilResourceCatalog(t *testing.T) {
+	var catalog *ResourceCatalog
+	assert.True(t, catalog.HasResourceType("k8s", "Pod"))
+	assert.Contains(t, catalog.ResourceTypes("arm"), "Microsoft.Storage/storageAccounts")
+}
@@ -15,6 +15,10 @@
 package forms
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
+
 	"github.com/erikgeiser/promptkit/confirmation"
 	"github.com/erikgeiser/promptkit/selection"
 	"github.com/erikgeiser/promptkit/textinput"
@@ -31,13 +35,43 @@ type (
 		Description string
 		Product     []string
 		InputType   string
-		SubForm     Form
+		// ResourceTypes pre-populates the resource types of the sub form. A
+		// single resource type selects a single-resource rule and more select
+		// a multi-resource rule.
+		ResourceTypes []string
+		// Relation pre-populates the relation of a multi-resource rule.
+		Relation string
+		// Links pre-populates the links of the resource types after the
+		// second one, see ParseResourceLinks.
+		Links []string
+		// Template is the name of the rule template.
+		Template string
+		// RegoTests selects whether a rego test file is generated along with
+		// the rule. It is prompted for when nil.
+		RegoTests *bool
+		// Compliance contains the remediation, references and controls of the
+		// rule. They are prompted for when nil.
+		Compliance *ComplianceFields
+		SubForm    Form
+	}
+
+	// ComplianceFields are the optional metadata fields that auditors rely
+	// on.
+	ComplianceFields struct {
+		Remediation string
+		// References are URLs of documentation about the rule.
+		References []string
+		// Controls are compliance controls of the form
+		// <framework>:<version>:<control>, e.g. CIS-AWS:v1.4.0:2.1.5.
+		Controls []string
 	}
 
 	RuleForm struct {
-		Project *project.Project
-		Fields  RuleFields
-		Logger  *zerolog.Logger
+		Project   *project.Project
+		Templates *Templates
+		Resources *ResourceCatalog
+		Fields    RuleFields
+		Logger    *zerolog.Logger
 	}
 )
 
@@ -60,6 +94,12 @@ func (f *RuleForm) Run() error {
 	if err := f.promptInputType(); err != nil {
 		return err
 	}
+	if err := f.promptCompliance(); err != nil {
+		return err
+	}
+	if err := f.promptRegoTests(); err != nil {
+		return err
+	}
 	if err := f.runSubForm(); err != nil {
 		return err
 	}
@@ -67,10 +107,6 @@ func (f *RuleForm) Run() error {
 }
 
 func (f *RuleForm) promptRuleID() error {
-	if f.Fields.RuleID != "" {
-		return nil
-	}
-
 	var existingIDs []string
 	metadata, err := f.Project.RuleMetadata()
 	if err == nil {
@@ -79,6 +115,10 @@ func (f *RuleForm) promptRuleID() error {
 		}
 	}
 	existingDirs := f.Project.ListRules()
+	if f.Fields.RuleID != "" {
+		return ruleIDValidator(existingIDs, existingDirs)(f.Fields.RuleID)
+	}
+
 	prompt := textinput.New("Rule ID:")
 	prompt.Placeholder = "ACMECORP_001"
 	prompt.CharLimit = ruleIDMaxLength
@@ -95,16 +135,10 @@ func (f *RuleForm) promptRuleID() error {
 
 func (f *RuleForm) promptSeverity() error {
 	if f.Fields.Severity != "" {
-		return nil
+		return choiceValidator("severity", severities)(f.Fields.Severity)
 	}
 
-	prompt := selection.New("Severity:", []string{
-		"critical",
-		"high",
-		"medium",
-		"low",
-		"informational",
-	})
+	prompt := selection.New("Severity:", severities)
 	severity, err := prompt.RunPrompt()
 	if err != nil {
 		return err
@@ -176,14 +210,14 @@ func (f *RuleForm) promptProduct() error {
 }
 
 func (f *RuleForm) promptInputType() error {
-	if f.Fields.InputType != "" {
-		return nil
-	}
-
 	choices := allInputTypes()
 	if len(f.Fields.Product) == 1 && f.Fields.Product[0] == "iac" {
 		choices = iacInputTypes()
 	}
+	if f.Fields.InputType != "" {
+		return choiceValidator("input type", choices)(f.Fields.InputType)
+	}
+
 	prompt := selection.New("Input type:", choices)
 	inputType, err := prompt.RunPrompt()
 	if err != nil {
@@ -194,40 +228,212 @@ func (f *RuleForm) promptInputType() error {
 	return nil
 }
 
+func (f *RuleForm) promptCompliance() error {
+	if f.Fields.Compliance != nil {
+		return f.Fields.Compliance.validate()
+	}
+
+	var fields ComplianceFields
+	prompt := textinput.New("Remediation (optional):")
+	prompt.Placeholder = "Set the bucket's ACL to private"
+	prompt.CharLimit = 1024
+	prompt.Validate = nil
+	remediation, err := prompt.RunPrompt()
+	if err != nil {
+		return err
+	}
+	fields.Remediation = strings.TrimSpace(remediation)
+
+	prompt = textinput.New("Reference URLs, separated by commas (optional):")
+	prompt.Placeholder = "https://docs.aws.amazon.com/AmazonS3/latest/userguide/acls.html"
+	prompt.Validate = func(value string) error {
+		return validateReferences(splitCommas(value))
+	}
+	prompt.Template = verboseValidationTemplate
+	references, err := prompt.RunPrompt()
+	if err != nil {
+		return err
+	}
+	fields.References = splitCommas(references)
+
+	prompt = textinput.New("Compliance controls as framework:version:control, separated by commas (optional):")
+	prompt.Placeholder = "CIS-AWS:v1.4.0:2.1.5"
+	prompt.Validate = func(value string) error {
+		return validateControls(splitCommas(value))
+	}
+	prompt.Template = verboseValidationTemplate
+	controls, err := prompt.RunPrompt()
+	if err != nil {
+		return err
+	}
+	fields.Controls = splitCommas(controls)
+
+	f.Fields.Compliance = &fields
+	return nil
+}
+
+func (f *RuleForm) promptRegoTests() error {
+	if f.Fields.RegoTests != nil {
+		return nil
+	}
+
+	prompt := confirmation.New("Would you like to generate example rego tests for this rule?", confirmation.No)
+	regoTests, err := prompt.RunPrompt()
+	if err != nil {
+		return err
+	}
+
+	f.Fields.RegoTests = &regoTests
+	return nil
+}
+
 func (f *RuleForm) runSubForm() error {
 	if f.Fields.SubForm != nil {
 		return nil
 	}
 
-	prompt := confirmation.New("Does this rule need more than one resource type?", confirmation.No)
-	choice, err := prompt.RunPrompt()
+	var multi bool
+	switch len(f.Fields.ResourceTypes) {
+	case 0:
+		prompt := confirmation.New("Does this rule need more than one resource type?", confirmation.No)
+		var err error
+		multi, err = prompt.RunPrompt()
+		if err != nil {
+			return err
+		}
+	default:
+		multi = len(f.Fields.ResourceTypes) > 1
+	}
+
+	if err := promptTemplate("rule", f.Templates.RuleTemplateNames(multi), &f.Fields.Template); err != nil {
+		return err
+	}
+
+	form, err := ruleSubForm(f.Project, f.Fields, multi, f.Templates, f.Resources, f.Logger)
 	if err != nil {
 		return err
 	}
+	f.Fields.SubForm = form
+	return f.Fields.SubForm.Run()
+}
 
+// ruleSubForm returns the form that templates the rule, pre-populated with
+// the resource types, relation, links and template from the given fields.
+func ruleSubForm(
+	proj *project.Project,
+	fields RuleFields,
+	multi bool,
+	templates *Templates,
+	resources *ResourceCatalog,
+	logger *zerolog.Logger,
+) (Form, error) {
 	metadata := &project.RuleMetadata{
-		ID:          f.Fields.RuleID,
-		Severity:    f.Fields.Severity,
-		Title:       f.Fields.Title,
-		Description: f.Fields.Description,
-		Product:     f.Fields.Product,
-	}
-	if choice {
-		f.Fields.SubForm = &MultiResourceRuleForm{
-			Project:   f.Project,
-			RuleID:    f.Fields.RuleID,
-			InputType: f.Fields.InputType,
+		ID:          fields.RuleID,
+		Severity:    fields.Severity,
+		Title:       fields.Title,
+		Description: fields.Description,
+		Product:     fields.Product,
+	}
+	if fields.Compliance != nil {
+		fields.Compliance.addTo(metadata, fields.InputType)
+	}
+	regoTests := fields.RegoTests != nil && *fields.RegoTests
+	if multi {
+		form := &MultiResourceRuleForm{
+			Project:   proj,
+			RuleID:    fields.RuleID,
+			InputType: fields.InputType,
 			Metadata:  metadata,
-			Logger:    f.Logger,
+			Templates: templates,
+			Template:  fields.Template,
+			Resources: resources,
+			RegoTests: regoTests,
+			Logger:    logger,
 		}
-	} else {
-		f.Fields.SubForm = &SingleResourceRuleForm{
-			Project:   f.Project,
-			RuleID:    f.Fields.RuleID,
-			InputType: f.Fields.InputType,
-			Metadata:  metadata,
-			Logger:    f.Logger,
+		if len(fields.ResourceTypes) > 1 {
+			links, err := ParseResourceLinks(fields.ResourceTypes, fields.Links)
+			if err != nil {
+				return nil, err
+			}
+			form.Fields = MultiResourceRuleFields{
+				PrimaryResourceType:   fields.ResourceTypes[0],
+				SecondaryResourceType: fields.ResourceTypes[1],
+				Relation:              fields.Relation,
+				Links:                 links,
+			}
 		}
+		return form, nil
 	}
-	return f.Fields.SubForm.Run()
+	form := &SingleResourceRuleForm{
+		Project:   proj,
+		RuleID:    fields.RuleID,
+		InputType: fields.InputType,
+		Metadata:  metadata,
+		Templates: templates,
+		Template:  fields.Template,
+		Resources: resources,
+		RegoTests: regoTests,
+		Logger:    logger,
+	}
+	if len(fields.ResourceTypes) == 1 {
+		form.Fields.ResourceType = fields.ResourceTypes[0]
+	}
+	return form, nil
+}
+
+func (c ComplianceFields) validate() error {
+	if err := validateReferences(c.References); err != nil {
+		return err
+	}
+	return validateControls(c.Controls)
+}
+
+// addTo adds the fields to the metadata of a rule. The remediation applies to
+// the input type of the rule.
+func (c ComplianceFields) addTo(metadata *project.RuleMetadata, inputType string) {
+	if c.Remediation != "" {
+		metadata.Remediation = map[string]string{inputType: c.Remediation}
+	}
+	for _, u := range c.References {
+		if metadata.References == nil {
+			metadata.References = map[string][]project.MetadataReference{}
+		}
+		metadata.References[project.GeneralReferences] = append(
+			metadata.References[project.GeneralReferences],
+			project.MetadataReference{URL: u},
+		)
+	}
+	for _, control := range c.Controls {
+		// The controls are validated before the rule is templated.
+		_ = metadata.AddControl(control)
+	}
+}
+
+func validateReferences(references []string) error {
+	for _, r := range references {
+		u, err := url.Parse(r)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid reference '%s', must be an http or https URL", r)
+		}
+	}
+	return nil
+}
+
+func validateControls(controls []string) error {
+	for _, c := range controls {
+		if _, _, _, err := project.ParseControl(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitCommas(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
 }
@@ -17,6 +17,7 @@ package forms
 import (
 	"bytes"
 	_ "embed"
+	"fmt"
 	"text/template"
 
 	"github.com/open-policy-agent/opa/format"
@@ -31,65 +32,168 @@ var singleRegoTmpl string
 //go:embed ruletemplates/relation.rego.tmpl
 var relationRegoTmpl string
 
-var multiResourceRuleTemplate = template.Must(
-	template.New("MultiResourceRule").Parse(multiRegoTmpl))
+//go:embed ruletemplates/relation_keys.rego.tmpl
+var keysRelationRegoTmpl string
 
-var singleResourceRuleTemplate = template.Must(
-	template.New("SingleResourceRule").Parse(singleRegoTmpl))
+//go:embed ruletemplates/relation_nested.rego.tmpl
+var nestedRelationRegoTmpl string
 
-var relationTemplate = template.Must(
-	template.New("Relation").Parse(relationRegoTmpl))
+//go:embed ruletemplates/multi_test.rego.tmpl
+var multiTestRegoTmpl string
 
+//go:embed ruletemplates/single_test.rego.tmpl
+var singleTestRegoTmpl string
+
+// multiResourceRuleParams are the parameters of multi-resource rule
+// templates. MissingMetadata lists the remediation, references and controls
+// fields that are missing from the metadata, e.g. "references, controls".
 type multiResourceRuleParams struct {
 	RulePackage               string
 	InputType                 string
 	RuleMetadata              string
+	MissingMetadata           string
 	PrimaryResourceType       string
 	PrimaryResourcePlural     string
 	PrimaryResourceSingular   string
+	SecondaryResourceType     string
 	SecondaryResourcePlural   string
 	SecondaryResourceSingular string
 	Relation                  string
+	// AdditionalResources are the resource types after the secondary one.
+	AdditionalResources []relatedResourceParams
+	// MockRelations are the relations that the rego tests mock.
+	MockRelations []mockRelationParams
 }
 
-func templateMultiResourceRule(params multiResourceRuleParams) ([]byte, error) {
-	var buf bytes.Buffer
-	err := multiResourceRuleTemplate.Execute(&buf, params)
+// mockRelationParams describe a mock of snyk.relates for a relation looked up
+// from resources of ResourceType. Valid and Invalid are the names of the mock
+// resources that it returns in the valid and invalid test cases. Links that
+// share a resource type and relation share a mock, so that the mocks don't
+// conflict.
+type mockRelationParams struct {
+	ResourceType string
+	Relation     string
+	Valid        []string
+	Invalid      []string
+}
+
+// relatedResourceParams describe a resource type that is related to another
+// resource type of a multi-resource rule, its Parent.
+type relatedResourceParams struct {
+	ResourceType string
+	Singular     string
+	Plural       string
+	Relation     string
+	Parent       string
+	ParentPlural string
+	// Path contains the resource types between the primary resource type and
+	// the parent, including the parent.
+	Path []relatedResourceParams
+}
+
+func (t *Templates) multiResourceRule(name string, params multiResourceRuleParams) ([]byte, error) {
+	tmpl, err := lookupTemplate(t.orDefault().multi, "multi-resource rule", name)
 	if err != nil {
 		return nil, err
 	}
-	return format.Source("", buf.Bytes())
+	return executeRego(tmpl, params)
 }
 
+// multiResourceRuleTest templates the rego tests of a multi-resource rule. The
+// test template with the same name as the rule template is used if there is
+// one.
+func (t *Templates) multiResourceRuleTest(name string, params multiResourceRuleParams) ([]byte, error) {
+	return executeRego(testTemplate(t.orDefault().multiTests, name), params)
+}
+
+// singleResourceRuleParams are the parameters of single-resource rule
+// templates. MissingMetadata is the same as for multi-resource rules.
 type singleResourceRuleParams struct {
-	RulePackage  string
-	InputType    string
-	RuleMetadata string
-	ResourceType string
+	RulePackage     string
+	InputType       string
+	RuleMetadata    string
+	MissingMetadata string
+	ResourceType    string
 }
 
-func templateSingleResourceRule(params singleResourceRuleParams) ([]byte, error) {
-	var buf bytes.Buffer
-	err := singleResourceRuleTemplate.Execute(&buf, params)
+func (t *Templates) singleResourceRule(name string, params singleResourceRuleParams) ([]byte, error) {
+	tmpl, err := lookupTemplate(t.orDefault().single, "single-resource rule", name)
 	if err != nil {
 		return nil, err
 	}
-	return format.Source("", buf.Bytes())
+	return executeRego(tmpl, params)
 }
 
+// singleResourceRuleTest templates the rego tests of a single-resource rule.
+// The test template with the same name as the rule template is used if there
+// is one.
+func (t *Templates) singleResourceRuleTest(name string, params singleResourceRuleParams) ([]byte, error) {
+	return executeRego(testTemplate(t.orDefault().singleTests, name), params)
+}
+
+// relationParams are the parameters of relation templates. LeftKey and
+// RightKey are rego expressions of the attributes of the resources, which are
+// bound to left and right. LeftPath and RightPath contain the same keys, but
+// with the nested blocks bound to variables.
 type relationParams struct {
 	Name              string
 	LeftResourceType  string
 	LeftAttributes    []string
+	LeftKey           string
+	LeftPath          relationPath
 	RightResourceType string
 	RightAttributes   []string
+	RightKey          string
+	RightPath         relationPath
 }
 
-func templateRelation(params relationParams) (string, error) {
-	var buf bytes.Buffer
-	err := relationTemplate.Execute(&buf, params)
+// relationPath is the key of one side of a relation, read through the nested
+// blocks of a resource.
+type relationPath struct {
+	Blocks []relationBlock
+	Key    string
+}
+
+// relationBlock binds the elements of a list of nested blocks to Var.
+type relationBlock struct {
+	Var string
+	Ref string
+}
+
+func (t *Templates) relation(name string, params relationParams) (string, error) {
+	tmpl, err := lookupTemplate(t.orDefault().relations, "relation", name)
 	if err != nil {
 		return "", err
 	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
 	return buf.String(), nil
 }
+
+func testTemplate(templates map[string]*template.Template, name string) *template.Template {
+	if tmpl, ok := templates[name]; ok {
+		return tmpl
+	}
+	return templates[DefaultTemplate]
+}
+
+func executeRego(tmpl *template.Template, params any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, err
+	}
+	return format.Source("", buf.Bytes())
+}
+
+func lookupTemplate(templates map[string]*template.Template, kind string, name string) (*template.Template, error) {
+	if name == "" {
+		name = DefaultTemplate
+	}
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s template '%s'", kind, name)
+	}
+	return tmpl, nil
+}
@@ -16,8 +16,8 @@ package forms
 
 import (
 	"encoding/json"
+	"strings"
 
-	"github.com/erikgeiser/promptkit/textinput"
 	"github.com/rs/zerolog"
 	"github.com/snyk/cli-extension-iac-rules/internal/project"
 )
@@ -32,6 +32,13 @@ type (
 		RuleID    string
 		InputType string
 		Metadata  *project.RuleMetadata
+		Templates *Templates
+		Resources *ResourceCatalog
+		// Template is the name of the rule template, or empty for the default
+		// template.
+		Template string
+		// RegoTests adds a main_test.rego file with example tests.
+		RegoTests bool
 		Fields    SingleResourceRuleFields
 		Logger    *zerolog.Logger
 	}
@@ -50,12 +57,14 @@ func (f *SingleResourceRuleForm) Run() error {
 	if err != nil {
 		return err
 	}
-	rule, err := templateSingleResourceRule(singleResourceRuleParams{
-		RulePackage:  rulePackage,
-		InputType:    f.InputType,
-		RuleMetadata: string(metadataJSON),
-		ResourceType: f.Fields.ResourceType,
-	})
+	params := singleResourceRuleParams{
+		RulePackage:     rulePackage,
+		InputType:       f.InputType,
+		RuleMetadata:    string(metadataJSON),
+		MissingMetadata: strings.Join(f.Metadata.MissingAuditFields(), ", "),
+		ResourceType:    f.Fields.ResourceType,
+	}
+	rule, err := f.Templates.singleResourceRule(f.Template, params)
 	if err != nil {
 		return err
 	}
@@ -64,15 +73,24 @@ func (f *SingleResourceRuleForm) Run() error {
 		return err
 	}
 	f.Logger.Info().Msgf("Writing rule to %s", path)
-	return nil
+
+	if !f.RegoTests {
+		return nil
+	}
+	test, err := f.Templates.singleResourceRuleTest(f.Template, params)
+	if err != nil {
+		return err
+	}
+	return addRegoTests(f.Project, f.RuleID, test, f.Logger)
 }
 
 func (f *SingleResourceRuleForm) promptResourceType() error {
 	if f.Fields.ResourceType != "" {
+		warnUnknownResourceType(f.Resources, f.InputType, f.Fields.ResourceType, f.Logger)
 		return nil
 	}
 
-	prompt := textinput.New("Resource type:")
+	prompt := resourceTypePrompt("Resource type:", f.Resources, f.InputType)
 	resourceType, err := prompt.RunPrompt()
 	if err != nil {
 		return err
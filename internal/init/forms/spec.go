@@ -30,14 +30,20 @@ type (
 		RuleID    string
 		Name      string
 		InputType string
+		// Template is the name of the spec stub.
+		Template string
 	}
 
 	SpecForm struct {
-		Project *project.Project
-		Client  *cloudapi.Client
-		OrgID   string
-		Fields  SpecFields
-		Logger  *zerolog.Logger
+		Project   *project.Project
+		Templates *Templates
+		Client    *cloudapi.Client
+		OrgID     string
+		Fields    SpecFields
+		// ResourceTypes pre-populates the resource type filter of cloud
+		// specs.
+		ResourceTypes []string
+		Logger        *zerolog.Logger
 	}
 )
 
@@ -59,11 +65,18 @@ func (f *SpecForm) Run() error {
 			OrgID:   f.OrgID,
 			RuleID:  f.Fields.RuleID,
 			Name:    f.Fields.Name,
+			Fields:  CloudSpecFields{ResourceTypes: f.ResourceTypes},
 			Logger:  f.Logger,
 		}
 		return form.Run()
 	} else {
-		filename, contents := specForInputType(f.Fields.InputType, f.Fields.Name)
+		if err := promptTemplate("spec", f.Templates.SpecTemplateNames(f.Fields.InputType), &f.Fields.Template); err != nil {
+			return err
+		}
+		filename, contents, err := f.Templates.spec(f.Fields.InputType, f.Fields.Template, f.Fields.Name)
+		if err != nil {
+			return err
+		}
 		path, err := f.Project.AddRuleSpec(f.Fields.RuleID, filename, contents)
 		if err != nil {
 			return err
@@ -125,7 +138,7 @@ func (f *SpecForm) promptName() error {
 
 func (f *SpecForm) promptInputType() error {
 	if f.Fields.InputType != "" {
-		return nil
+		return choiceValidator("input type", allInputTypes())(f.Fields.InputType)
 	}
 
 	var choices []string
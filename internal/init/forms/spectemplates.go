@@ -34,22 +34,31 @@ var k8sTmpl []byte
 //go:embed spectemplates/infra.tf
 var tfTmpl []byte
 
-func specForInputType(inputType string, name string) (filename string, contents []byte) {
-	switch inputType {
-	case input.Terraform.Name:
-		filename = addExtIfNeeded(name, ".tf")
-		contents = tfTmpl
-	case input.Kubernetes.Name:
-		filename = addExtIfNeeded(name, ".yaml")
-		contents = k8sTmpl
-	case input.CloudFormation.Name:
-		filename = addExtIfNeeded(name, ".yaml")
-		contents = cfnTmpl
-	case input.Arm.Name:
-		filename = addExtIfNeeded(name, ".json")
-		contents = armTmpl
+// specTemplate is a spec stub. Stubs are copied as they are, with the
+// extension of the template file.
+type specTemplate struct {
+	ext      string
+	contents []byte
+}
+
+func defaultSpecTemplates() map[string]map[string]specTemplate {
+	return map[string]map[string]specTemplate{
+		input.Terraform.Name:      {DefaultTemplate: {ext: ".tf", contents: tfTmpl}},
+		input.Kubernetes.Name:     {DefaultTemplate: {ext: ".yaml", contents: k8sTmpl}},
+		input.CloudFormation.Name: {DefaultTemplate: {ext: ".yaml", contents: cfnTmpl}},
+		input.Arm.Name:            {DefaultTemplate: {ext: ".json", contents: armTmpl}},
+	}
+}
+
+func (t *Templates) spec(inputType string, template string, name string) (filename string, contents []byte, err error) {
+	if template == "" {
+		template = DefaultTemplate
+	}
+	stub, ok := t.orDefault().specs[inputType][template]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown %s spec template '%s'", inputType, template)
 	}
-	return
+	return addExtIfNeeded(name, stub.ext), stub.contents, nil
 }
 
 func addExtIfNeeded(name, ext string) string {
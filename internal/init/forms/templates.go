@@ -0,0 +1,279 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/erikgeiser/promptkit/selection"
+	"github.com/spf13/afero"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+// DefaultTemplate is the name of the built-in templates. A user template with
+// this name replaces the built-in one.
+const DefaultTemplate = "default"
+
+// KeysRelationTemplate and NestedRelationTemplate are the names of the
+// built-in relation templates that write out the keys of a relation instead
+// of using snyk.relation_from_fields. Attributes of these relations can be
+// nested, e.g. ingress[].security_groups[].
+const (
+	KeysRelationTemplate   = "keys"
+	NestedRelationTemplate = "nested"
+)
+
+// ProjectTemplatesDir is the directory within a project that templates are
+// loaded from.
+const ProjectTemplatesDir = "templates"
+
+const (
+	singleTemplatesDir   = "single"
+	multiTemplatesDir    = "multi"
+	relationTemplatesDir = "relation"
+	singleTestsDir       = "single_test"
+	multiTestsDir        = "multi_test"
+	specTemplatesDir     = "spec"
+	regoTemplateExt      = ".rego.tmpl"
+)
+
+// Templates contains the rule, relation and spec templates that the forms can
+// choose from, by name. A nil *Templates only contains the built-in ones.
+//
+// A templates directory is laid out as follows:
+//
+//	single/<name>.rego.tmpl        single-resource rules
+//	multi/<name>.rego.tmpl         multi-resource rules
+//	relation/<name>.rego.tmpl      relations
+//	single_test/<name>.rego.tmpl   rego tests of single-resource rules
+//	multi_test/<name>.rego.tmpl    rego tests of multi-resource rules
+//	spec/<input type>/<name>.<ext> spec stubs
+type Templates struct {
+	single    map[string]*template.Template
+	multi     map[string]*template.Template
+	relations map[string]*template.Template
+	// singleTests and multiTests are the rego test templates. Rule templates
+	// use the test template with the same name, or else the default one.
+	singleTests map[string]*template.Template
+	multiTests  map[string]*template.Template
+	// specs maps input types to the spec stubs for that input type.
+	specs map[string]map[string]specTemplate
+}
+
+// DefaultTemplates returns the built-in templates.
+func DefaultTemplates() *Templates {
+	return &Templates{
+		single: map[string]*template.Template{
+			DefaultTemplate: template.Must(template.New("SingleResourceRule").Parse(singleRegoTmpl)),
+		},
+		multi: map[string]*template.Template{
+			DefaultTemplate: template.Must(template.New("MultiResourceRule").Parse(multiRegoTmpl)),
+		},
+		relations: map[string]*template.Template{
+			DefaultTemplate:        template.Must(template.New("Relation").Parse(relationRegoTmpl)),
+			KeysRelationTemplate:   template.Must(template.New("KeysRelation").Parse(keysRelationRegoTmpl)),
+			NestedRelationTemplate: template.Must(template.New("NestedRelation").Parse(nestedRelationRegoTmpl)),
+		},
+		singleTests: map[string]*template.Template{
+			DefaultTemplate: template.Must(template.New("SingleResourceRuleTest").Parse(singleTestRegoTmpl)),
+		},
+		multiTests: map[string]*template.Template{
+			DefaultTemplate: template.Must(template.New("MultiResourceRuleTest").Parse(multiTestRegoTmpl)),
+		},
+		specs: defaultSpecTemplates(),
+	}
+}
+
+// UserTemplatesDir returns the directory that holds the templates of the
+// current user, e.g. ~/.config/snyk/iac-rules/templates on Linux.
+func UserTemplatesDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snyk", "iac-rules", "templates"), nil
+}
+
+// LoadTemplates returns the built-in templates, extended or overridden by the
+// templates in the user templates directory, in the directories listed in the
+// project's manifest and in the project's templates directory, in that order.
+func LoadTemplates(proj *project.Project) (*Templates, error) {
+	t := DefaultTemplates()
+	if dir, err := UserTemplatesDir(); err == nil {
+		if err := t.LoadDir(afero.NewOsFs(), dir); err != nil {
+			return nil, err
+		}
+	}
+	dirs := proj.Manifest().Templates
+	dirs = append(dirs, ProjectTemplatesDir)
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(proj.Path(), dir)
+		}
+		if err := t.LoadDir(proj.FS, dir); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// LoadDir adds the templates in the given directory, replacing templates with
+// the same name. A directory that does not exist is ignored.
+func (t *Templates) LoadDir(fsys afero.Fs, dir string) error {
+	for subdir, templates := range map[string]map[string]*template.Template{
+		singleTemplatesDir:   t.single,
+		multiTemplatesDir:    t.multi,
+		relationTemplatesDir: t.relations,
+		singleTestsDir:       t.singleTests,
+		multiTestsDir:        t.multiTests,
+	} {
+		paths, err := readDirFiles(fsys, filepath.Join(dir, subdir))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if !strings.HasSuffix(path, regoTemplateExt) {
+				continue
+			}
+			b, err := afero.ReadFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(filepath.Base(path), regoTemplateExt)
+			tmpl, err := template.New(name).Parse(string(b))
+			if err != nil {
+				return fmt.Errorf("failed to parse template %s: %w", path, err)
+			}
+			templates[name] = tmpl
+		}
+	}
+
+	inputTypes, err := afero.ReadDir(fsys, filepath.Join(dir, specTemplatesDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, info := range inputTypes {
+		if !info.IsDir() {
+			continue
+		}
+		inputType := info.Name()
+		if err := choiceValidator("spec template input type", iacInputTypes())(inputType); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Join(dir, specTemplatesDir, inputType), err)
+		}
+		paths, err := readDirFiles(fsys, filepath.Join(dir, specTemplatesDir, inputType))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			b, err := afero.ReadFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(path)
+			name := strings.TrimSuffix(filepath.Base(path), ext)
+			if t.specs[inputType] == nil {
+				t.specs[inputType] = map[string]specTemplate{}
+			}
+			t.specs[inputType][name] = specTemplate{ext: ext, contents: b}
+		}
+	}
+	return nil
+}
+
+// RuleTemplateNames lists the names of the single- or multi-resource rule
+// templates, starting with the default template.
+func (t *Templates) RuleTemplateNames(multi bool) []string {
+	if multi {
+		return templateNames(t.orDefault().multi)
+	}
+	return templateNames(t.orDefault().single)
+}
+
+// RelationTemplateNames lists the names of the relation templates, starting
+// with the default template.
+func (t *Templates) RelationTemplateNames() []string {
+	return templateNames(t.orDefault().relations)
+}
+
+// SpecTemplateNames lists the names of the spec stubs for the given input
+// type, starting with the default template.
+func (t *Templates) SpecTemplateNames(inputType string) []string {
+	return templateNames(t.orDefault().specs[inputType])
+}
+
+func (t *Templates) orDefault() *Templates {
+	if t == nil {
+		return DefaultTemplates()
+	}
+	return t
+}
+
+func templateNames[T any](templates map[string]T) []string {
+	var names []string
+	for name := range templates {
+		if name != DefaultTemplate {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := templates[DefaultTemplate]; ok {
+		names = append([]string{DefaultTemplate}, names...)
+	}
+	return names
+}
+
+func readDirFiles(fsys afero.Fs, dir string) ([]string, error) {
+	infos, err := afero.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var paths []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			paths = append(paths, filepath.Join(dir, info.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// promptTemplate validates a pre-populated template name, or lets the user
+// choose one when there is more than one template to choose from.
+func promptTemplate(kind string, names []string, template *string) error {
+	if *template != "" {
+		return choiceValidator(kind+" template", names)(*template)
+	}
+	if len(names) < 2 {
+		*template = DefaultTemplate
+		return nil
+	}
+	prompt := selection.New(fmt.Sprintf("Choose a %s template:", kind), names)
+	choice, err := prompt.RunPrompt()
+	if err != nil {
+		return err
+	}
+	*template = choice
+	return nil
+}
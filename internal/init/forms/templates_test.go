@@ -0,0 +1,157 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forms
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplatesLoadDir(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"templates/single/default.rego.tmpl": "package rules.{{.RulePackage}}\n\nresource_type := \"{{.ResourceType}}\"\n",
+		"templates/single/acme.rego.tmpl":    "package rules.{{.RulePackage}}\n\nimport data.acme.helpers\n",
+		"templates/single/README.md":         "ignored",
+		"templates/spec/tf/acme.tf":          "# acme\n",
+	}
+	for path, contents := range files {
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(contents), 0644))
+	}
+
+	templates := DefaultTemplates()
+	require.NoError(t, templates.LoadDir(fsys, "templates"))
+	require.NoError(t, templates.LoadDir(fsys, "missing"))
+
+	assert.Equal(t, []string{DefaultTemplate, "acme"}, templates.RuleTemplateNames(false))
+	assert.Equal(t, []string{DefaultTemplate}, templates.RuleTemplateNames(true))
+	assert.Equal(t, []string{DefaultTemplate, "acme"}, templates.SpecTemplateNames("tf"))
+	assert.Equal(t, []string{DefaultTemplate}, templates.SpecTemplateNames("k8s"))
+
+	rule, err := templates.singleResourceRule("", singleResourceRuleParams{
+		RulePackage:  "acme_001",
+		ResourceType: "aws_s3_bucket",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "package rules.acme_001\n\nresource_type := \"aws_s3_bucket\"\n", string(rule))
+
+	rule, err = templates.singleResourceRule("acme", singleResourceRuleParams{RulePackage: "acme_001"})
+	require.NoError(t, err)
+	assert.Contains(t, string(rule), "import data.acme.helpers")
+
+	_, err = templates.singleResourceRule("missing", singleResourceRuleParams{})
+	assert.ErrorContains(t, err, "unknown single-resource rule template 'missing'")
+
+	filename, contents, err := templates.spec("tf", "acme", "infra")
+	require.NoError(t, err)
+	assert.Equal(t, "infra.tf", filename)
+	assert.Equal(t, "# acme\n", string(contents))
+}
+
+func TestTemplatesLoadDirErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		contents string
+		expected string
+	}{
+		{
+			name:     "invalid template",
+			path:     "templates/multi/broken.rego.tmpl",
+			contents: "package rules.{{.RulePackage",
+			expected: "failed to parse template templates/multi/broken.rego.tmpl",
+		},
+		{
+			name:     "unknown input type",
+			path:     "templates/spec/yaml/stub.yaml",
+			contents: "",
+			expected: "invalid spec template input type 'yaml'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fsys, tc.path, []byte(tc.contents), 0644))
+			err := DefaultTemplates().LoadDir(fsys, "templates")
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestNilTemplates(t *testing.T) {
+	var templates *Templates
+	assert.Equal(t, []string{DefaultTemplate, KeysRelationTemplate, NestedRelationTemplate}, templates.RelationTemplateNames())
+	filename, contents, err := templates.spec("k8s", "", "deployment")
+	require.NoError(t, err)
+	assert.Equal(t, "deployment.yaml", filename)
+	assert.Equal(t, k8sTmpl, contents)
+}
+
+func TestDefaultRuleTestTemplates(t *testing.T) {
+	var templates *Templates
+	single, err := templates.singleResourceRuleTest("", singleResourceRuleParams{
+		RulePackage:  "ACME_001",
+		InputType:    "tf",
+		ResourceType: "aws_s3_bucket",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(single), "package rules.ACME_001")
+	assert.Contains(t, string(single), "count(deny) == 1 with input as invalid_resource")
+
+	multi, err := templates.multiResourceRuleTest("custom", multiResourceRuleParams{
+		RulePackage:               "ACME_002",
+		InputType:                 "tf",
+		PrimaryResourceType:       "aws_s3_bucket",
+		PrimaryResourceSingular:   "bucket",
+		PrimaryResourcePlural:     "buckets",
+		SecondaryResourceType:     "aws_s3_bucket_acl",
+		SecondaryResourceSingular: "acl",
+		SecondaryResourcePlural:   "acls",
+		Relation:                  "acl",
+		MockRelations: []mockRelationParams{{
+			ResourceType: "aws_s3_bucket",
+			Relation:     "acl",
+			Valid:        []string{"valid_acl"},
+			Invalid:      []string{"invalid_acl"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(multi), "package rules.ACME_002")
+	assert.Contains(t, string(multi), `resource_type == "aws_s3_bucket"`)
+	assert.Contains(t, string(multi), "mock_relates_invalid")
+}
+
+func TestDefaultRuleTemplatesMissingMetadata(t *testing.T) {
+	var templates *Templates
+	params := singleResourceRuleParams{
+		RulePackage:     "ACME_001",
+		InputType:       "tf",
+		RuleMetadata:    `{"id": "ACME-001"}`,
+		MissingMetadata: "references, controls",
+		ResourceType:    "aws_s3_bucket",
+	}
+	rule, err := templates.singleResourceRule("", params)
+	require.NoError(t, err)
+	assert.Contains(t, string(rule), "# TODO: add references, controls to the metadata\nmetadata := {\"id\": \"ACME-001\"}")
+
+	params.MissingMetadata = ""
+	rule, err = templates.singleResourceRule("", params)
+	require.NoError(t, err)
+	assert.NotContains(t, string(rule), "to the metadata")
+	assert.Contains(t, string(rule), "\nmetadata := {\"id\": \"ACME-001\"}")
+}
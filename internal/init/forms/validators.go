@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
 )
 
 var verboseValidationTemplate = `
@@ -33,6 +35,37 @@ var ruleIDPrefixCharset = regexp.MustCompile(`^[A-Za-z]`)
 var ruleIDCharset = regexp.MustCompile(`^[A-Za-z0-9-_]*$`)
 var ruleIDReservedPrefixes = []string{"SNYK_", "SNYK-", "FG_R"}
 
+var severities = []string{
+	"critical",
+	"high",
+	"medium",
+	"low",
+	"informational",
+}
+
+// ValidateSeverity checks that the given severity is supported for rules.
+func ValidateSeverity(severity string) error {
+	return choiceValidator("severity", severities)(severity)
+}
+
+// ValidateProduct checks that the given product is supported for rules.
+func ValidateProduct(product string) error {
+	return choiceValidator("product", []string{"iac", "cloud"})(product)
+}
+
+// choiceValidator checks pre-populated fields that are otherwise picked from
+// a selection prompt.
+func choiceValidator(name string, choices []string) func(string) error {
+	return func(value string) error {
+		for _, c := range choices {
+			if value == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s '%s', must be one of: %s", name, value, strings.Join(choices, ", "))
+	}
+}
+
 func ruleIDValidator(existingIDs []string, existingDirs []string) func(string) error {
 	return func(ruleID string) error {
 		if len(ruleID) < 1 {
@@ -69,3 +102,29 @@ func ruleIDValidator(existingIDs []string, existingDirs []string) func(string) e
 		return nil
 	}
 }
+
+// ValidateRuleID checks a new rule ID against the rules in the project. The
+// rule with the ignored ID, e.g. a rule that is being renamed, does not count
+// as a conflict.
+func ValidateRuleID(proj *project.Project, ruleID string, ignore string) error {
+	var existingIDs []string
+	metadata, err := proj.RuleMetadata()
+	if err == nil {
+		for id := range metadata {
+			if id != ignore {
+				existingIDs = append(existingIDs, id)
+			}
+		}
+	}
+	ignoreDir, _ := project.SafePackageName(ignore)
+	var existingDirs []string
+	for _, dir := range proj.ListRules() {
+		if dir != ignoreDir {
+			existingDirs = append(existingDirs, dir)
+		}
+	}
+	if ruleID == "" {
+		return fmt.Errorf("rule ID is missing")
+	}
+	return ruleIDValidator(existingIDs, existingDirs)(ruleID)
+}
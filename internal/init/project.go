@@ -28,6 +28,15 @@ func ProjectWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
+	answers, err := answersFromConfig(ictx.GetConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	return initProject(ictx, answers)
+}
+
+// initProject scaffolds a project, prompting for the answers that are missing.
+func initProject(ictx workflow.InvocationContext, answers Answers) ([]workflow.Data, error) {
 	logger := ictx.GetEnhancedLogger()
 	wd, err := os.Getwd()
 	if err != nil {
@@ -44,12 +53,16 @@ func ProjectWorkflow(
 	form := &forms.ProjectForm{
 		Project:     proj,
 		DefaultName: defaultName,
+		Fields:      forms.ProjectFields{Name: answers.Name},
 		Logger:      logger,
 	}
+	if form.Fields.Name == "" && !interactive() {
+		form.Fields.Name = defaultName
+	}
 	if err := form.Run(); err != nil {
 		return nil, err
 	}
-	if err := proj.WriteChanges(); err != nil {
+	if err := writeChanges(ictx, proj); err != nil {
 		return nil, err
 	}
 	return []workflow.Data{}, nil
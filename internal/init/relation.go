@@ -25,19 +25,51 @@ func RelationWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
+	answers, err := answersFromConfig(ictx.GetConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	return initRelation(ictx, answers)
+}
+
+// initRelation scaffolds a relation, prompting for the answers that are missing.
+func initRelation(ictx workflow.InvocationContext, answers Answers) ([]workflow.Data, error) {
 	logger := ictx.GetEnhancedLogger()
 	proj, err := project.FromDir(afero.NewOsFs(), ".")
 	if err != nil {
 		return nil, err
 	}
+	templates, err := forms.LoadTemplates(proj)
+	if err != nil {
+		return nil, err
+	}
+	fields := forms.RelationFields{
+		Name:                answers.Name,
+		PrimaryAttributes:   answers.PrimaryAttributes,
+		SecondaryAttributes: answers.SecondaryAttributes,
+		Template:            answers.template(),
+	}
+	if len(answers.ResourceTypes) > 0 {
+		fields.PrimaryResourceType = answers.ResourceTypes[0]
+	}
+	if len(answers.ResourceTypes) > 1 {
+		fields.SecondaryResourceType = answers.ResourceTypes[1]
+	}
+	if err := requireAnswers("relation", missingRelationFields(fields)); err != nil {
+		return nil, err
+	}
 	form := &forms.RelationForm{
-		Project: proj,
-		Logger:  logger,
+		Project:   proj,
+		Templates: templates,
+		Resources: forms.LoadResourceCatalog(proj),
+		InputType: answers.InputType,
+		Fields:    fields,
+		Logger:    logger,
 	}
 	if err := form.Run(); err != nil {
 		return nil, err
 	}
-	if err := proj.WriteChanges(); err != nil {
+	if err := writeChanges(ictx, proj); err != nil {
 		return nil, err
 	}
 	return []workflow.Data{}, nil
@@ -0,0 +1,193 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/erikgeiser/promptkit/selection"
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+const flagYes = "yes"
+
+// errAborted is returned when the user aborts the review of the generated
+// files.
+var errAborted = errors.New("aborted, no files were written")
+
+const (
+	reviewAccept = "Write the files"
+	reviewEdit   = "Edit a file"
+	reviewAbort  = "Abort"
+)
+
+// writeChanges lets the user review the generated files before they are
+// written, unless there is no TTY or --yes is given.
+func writeChanges(ictx workflow.InvocationContext, proj *project.Project) error {
+	if interactive() && !ictx.GetConfiguration().GetBool(flagYes) {
+		if err := reviewChanges(os.Stdout, proj); err != nil {
+			return err
+		}
+	}
+	return proj.WriteChanges()
+}
+
+// reviewChanges shows the pending changes of the project and prompts to
+// accept them, edit one of the files in $EDITOR or abort.
+func reviewChanges(w io.Writer, proj *project.Project) error {
+	changes, err := proj.PendingChanges()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Fprint(w, formatChange(c))
+	}
+	fmt.Fprint(w, formatSummary(changes))
+	for {
+		prompt := selection.New("Write these files?", []string{reviewAccept, reviewEdit, reviewAbort})
+		choice, err := prompt.RunPrompt()
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case reviewAccept:
+			return nil
+		case reviewAbort:
+			return errAborted
+		}
+
+		var editable []string
+		for _, c := range changes {
+			if c.Kind != project.ChangeDelete {
+				editable = append(editable, c.Path)
+			}
+		}
+		if len(editable) == 0 {
+			fmt.Fprintln(w, "There are no files to edit.")
+			continue
+		}
+		path, err := selection.New("Which file do you want to edit?", editable).RunPrompt()
+		if err != nil {
+			return err
+		}
+		if err := editChange(proj, changes, path); err != nil {
+			return err
+		}
+		changes, err = proj.PendingChanges()
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if c.Path == path {
+				fmt.Fprint(w, formatChange(c))
+			}
+		}
+		fmt.Fprint(w, formatSummary(changes))
+	}
+}
+
+func editChange(proj *project.Project, changes []project.Change, path string) error {
+	for _, c := range changes {
+		if c.Path != path {
+			continue
+		}
+		edited, err := editInEditor(path, c.After)
+		if err != nil {
+			return err
+		}
+		return proj.UpdatePendingFile(path, edited)
+	}
+	return fmt.Errorf("%w: %s", project.ErrNoPendingChange, path)
+}
+
+// formatChange shows a created file as its contents and a modified file as a
+// unified diff.
+func formatChange(c project.Change) string {
+	switch c.Kind {
+	case project.ChangeCreate:
+		contents := string(c.After)
+		if contents != "" && !strings.HasSuffix(contents, "\n") {
+			contents += "\n"
+		}
+		return fmt.Sprintf("==> %s (new file)\n%s\n", c.Path, contents)
+	case project.ChangeModify:
+		before, after := string(c.Before), string(c.After)
+		edits := myers.ComputeEdits(span.URIFromPath(c.Path), before, after)
+		diff := gotextdiff.ToUnified(c.Path, c.Path, before, edits)
+		return fmt.Sprintf("==> %s (modified)\n%s\n", c.Path, diff)
+	}
+	return ""
+}
+
+func formatSummary(changes []project.Change) string {
+	var b strings.Builder
+	b.WriteString("The following changes will be written:\n")
+	for _, c := range changes {
+		fmt.Fprintf(&b, "  %-6s  %s\n", c.Kind, c.Path)
+	}
+	return b.String()
+}
+
+// editInEditor opens the contents in the user's editor and returns the
+// edited contents. The temporary file keeps the extension of the path so that
+// editors pick the right syntax highlighting.
+func editInEditor(path string, contents []byte) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "iac-rules-*"+filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], tmp.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run editor %s: %w", strings.Join(editor, " "), err)
+	}
+	return os.ReadFile(tmp.Name())
+}
+
+// editorCommand returns the user's editor from $VISUAL or $EDITOR, which may
+// include arguments such as "code --wait".
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	return []string{"vi"}
+}
@@ -0,0 +1,93 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+func TestFormatChange(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   project.Change
+		expected string
+	}{
+		{
+			name: "new file",
+			change: project.Change{
+				Path:  "rules/ACME_001/main.rego",
+				Kind:  project.ChangeCreate,
+				After: []byte("package rules.ACME_001"),
+			},
+			expected: "==> rules/ACME_001/main.rego (new file)\npackage rules.ACME_001\n\n",
+		},
+		{
+			name: "modified file",
+			change: project.Change{
+				Path:   "lib/relations.rego",
+				Kind:   project.ChangeModify,
+				Before: []byte("package relations\n"),
+				After:  []byte("package relations\n\nrelations[info] {\n\tinfo := {}\n}\n"),
+			},
+			expected: `==> lib/relations.rego (modified)
+--- lib/relations.rego
++++ lib/relations.rego
+@@ -1 +1,5 @@
+ package relations
++
++relations[info] {
++	info := {}
++}
+
+`,
+		},
+		{
+			name: "deleted file",
+			change: project.Change{
+				Path: "spec/rules/ACME_001/inputs/infra.tf",
+				Kind: project.ChangeDelete,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatChange(tc.change))
+		})
+	}
+}
+
+func TestFormatSummary(t *testing.T) {
+	assert.Equal(t, `The following changes will be written:
+  create  rules/ACME_001/main.rego
+  modify  lib/relations.rego
+`, formatSummary([]project.Change{
+		{Path: "rules/ACME_001/main.rego", Kind: project.ChangeCreate},
+		{Path: "lib/relations.rego", Kind: project.ChangeModify},
+	}))
+}
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	assert.Equal(t, []string{"vi"}, editorCommand())
+	t.Setenv("EDITOR", "code --wait")
+	assert.Equal(t, []string{"code", "--wait"}, editorCommand())
+	t.Setenv("VISUAL", "nano")
+	assert.Equal(t, []string{"nano"}, editorCommand())
+}
@@ -25,20 +25,50 @@ func RuleWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
+	answers, err := answersFromConfig(ictx.GetConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	return initRule(ictx, answers)
+}
+
+// initRule scaffolds a rule, prompting for the answers that are missing.
+func initRule(ictx workflow.InvocationContext, answers Answers) ([]workflow.Data, error) {
 	logger := ictx.GetEnhancedLogger()
 	proj, err := project.FromDir(afero.NewOsFs(), ".")
 	if err != nil {
 		return nil, err
 	}
 	checkProject(proj, logger)
+	templates, err := forms.LoadTemplates(proj)
+	if err != nil {
+		return nil, err
+	}
+	fields := answers.ruleFields()
+	fields.Template = answers.template()
+	if fields.RegoTests == nil && !interactive() {
+		// Without a TTY, only generate rego tests when asked to.
+		regoTests := false
+		fields.RegoTests = &regoTests
+	}
+	if fields.Compliance == nil && !interactive() {
+		// Remediation, references and controls are optional.
+		fields.Compliance = &forms.ComplianceFields{}
+	}
+	if err := requireAnswers("rule", missingRuleFields(fields)); err != nil {
+		return nil, err
+	}
 	form := &forms.RuleForm{
-		Project: proj,
-		Logger:  logger,
+		Project:   proj,
+		Templates: templates,
+		Resources: forms.LoadResourceCatalog(proj),
+		Fields:    fields,
+		Logger:    logger,
 	}
 	if err := form.Run(); err != nil {
 		return nil, err
 	}
-	if err := proj.WriteChanges(); err != nil {
+	if err := writeChanges(ictx, proj); err != nil {
 		return nil, err
 	}
 	return []workflow.Data{}, nil
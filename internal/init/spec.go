@@ -27,6 +27,15 @@ func SpecWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
+	answers, err := answersFromConfig(ictx.GetConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	return initSpec(ictx, answers)
+}
+
+// initSpec scaffolds a rule spec, prompting for the answers that are missing.
+func initSpec(ictx workflow.InvocationContext, answers Answers) ([]workflow.Data, error) {
 	logger := ictx.GetEnhancedLogger()
 	proj, err := project.FromDir(afero.NewOsFs(), ".")
 	if err != nil {
@@ -41,16 +50,37 @@ func SpecWorkflow(
 	if err != nil {
 		return nil, err
 	}
+	templates, err := forms.LoadTemplates(proj)
+	if err != nil {
+		return nil, err
+	}
+	fields := forms.SpecFields{
+		RuleID:    answers.ID,
+		Name:      answers.Name,
+		InputType: answers.InputType,
+		Template:  answers.template(),
+	}
+	if fields.InputType == "" && fields.RuleID != "" && !interactive() {
+		// Without a TTY, default to the input type of the rule like the prompt
+		// does.
+		fields.InputType, _ = proj.InputTypeForRule(fields.RuleID)
+	}
+	if err := requireAnswers("spec", missingSpecFields(fields, answers.ResourceTypes)); err != nil {
+		return nil, err
+	}
 	form := &forms.SpecForm{
-		Project: proj,
-		Client:  client,
-		OrgID:   config.GetString(configuration.ORGANIZATION),
-		Logger:  logger,
+		Project:       proj,
+		Templates:     templates,
+		Client:        client,
+		OrgID:         config.GetString(configuration.ORGANIZATION),
+		Fields:        fields,
+		ResourceTypes: answers.ResourceTypes,
+		Logger:        logger,
 	}
 	if err := form.Run(); err != nil {
 		return nil, err
 	}
-	if err := proj.WriteChanges(); err != nil {
+	if err := writeChanges(ictx, proj); err != nil {
 		return nil, err
 	}
 	return []workflow.Data{}, nil
@@ -29,6 +29,7 @@ const (
 	TypeRule     = "rule"
 	TypeSpec     = "rule spec"
 	TypeRelation = "relation"
+	TypeCatalog  = "rules from a catalog"
 )
 
 func TypeChoices() []TypeChoice {
@@ -37,12 +38,16 @@ func TypeChoices() []TypeChoice {
 		TypeRule,
 		TypeSpec,
 		TypeRelation,
+		TypeCatalog,
 	}
 }
 
 func RegisterWorkflows(e workflow.Engine) error {
 	workflowID := workflow.NewWorkflowIdentifier("iac.rules.init")
 	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-init", pflag.ExitOnError)
+	addAnswersFlags(flagset)
+	flagset.String(flagCatalog, "", "Path to a CSV or YAML catalog of rules to scaffold")
+	flagset.Bool(flagYes, false, "Write the generated files without reviewing them")
 	c := workflow.ConfigurationOptionsFromFlagset(flagset)
 	if _, err := e.Register(workflowID, c, initWorkflow); err != nil {
 		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
@@ -52,22 +57,37 @@ func RegisterWorkflows(e workflow.Engine) error {
 
 func initWorkflow(
 	ictx workflow.InvocationContext,
-	input []workflow.Data,
+	_ []workflow.Data,
 ) ([]workflow.Data, error) {
-	prompt := selection.New("What do you want to initialize?", TypeChoices())
-	choice, err := prompt.RunPrompt()
+	answers, err := answersFromConfig(ictx.GetConfiguration())
 	if err != nil {
 		return nil, err
 	}
+	choice, err := answers.typeChoice()
+	if err != nil {
+		return nil, err
+	}
+	if choice == "" {
+		if !interactive() {
+			return nil, fmt.Errorf("no TTY is available to prompt for what to initialize, pass it as the argument, e.g. init rule")
+		}
+		prompt := selection.New("What do you want to initialize?", TypeChoices())
+		choice, err = prompt.RunPrompt()
+		if err != nil {
+			return nil, err
+		}
+	}
 	switch choice {
 	case TypeProject:
-		return ProjectWorkflow(ictx, input)
+		return initProject(ictx, answers)
 	case TypeRule:
-		return RuleWorkflow(ictx, input)
+		return initRule(ictx, answers)
 	case TypeSpec:
-		return SpecWorkflow(ictx, input)
+		return initSpec(ictx, answers)
 	case TypeRelation:
-		return RelationWorkflow(ictx, input)
+		return initRelation(ictx, answers)
+	case TypeCatalog:
+		return CatalogWorkflow(ictx, nil)
 	default: // Should not happen
 		return nil, fmt.Errorf("nothing to initialize")
 	}
@@ -0,0 +1,221 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/init/forms"
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+const (
+	flagWhere        = "where"
+	flagSeverity     = "severity"
+	flagTitle        = "title"
+	flagDescription  = "description"
+	flagCategory     = "category"
+	flagServiceGroup = "service-group"
+	flagProduct      = "product"
+	flagPlatform     = "platform"
+	flagAddLabel     = "add-label"
+	flagRemoveLabel  = "remove-label"
+)
+
+func RegisterWorkflows(e workflow.Engine) error {
+	if err := registerGetWorkflow(e); err != nil {
+		return err
+	}
+	return registerSetWorkflow(e)
+}
+
+func registerGetWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.metadata.get")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-metadata-get", pflag.ExitOnError)
+
+	addWhereFlag(flagset)
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, getWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func registerSetWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.metadata.set")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-metadata-set", pflag.ExitOnError)
+
+	addWhereFlag(flagset)
+	flagset.String(flagSeverity, "", "New severity: critical, high, medium, low or informational")
+	flagset.String(flagTitle, "", "New title")
+	flagset.String(flagDescription, "", "New description")
+	flagset.String(flagCategory, "", "New category")
+	flagset.String(flagServiceGroup, "", "New service group")
+	flagset.StringSlice(flagProduct, nil, "Replace the products: iac, cloud or both")
+	flagset.StringSlice(flagPlatform, nil, "Replace the platforms")
+	flagset.StringSlice(flagAddLabel, nil, "Add a label (can be repeated)")
+	flagset.StringSlice(flagRemoveLabel, nil, "Remove a label (can be repeated)")
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, setWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func addWhereFlag(flagset *pflag.FlagSet) {
+	flagset.StringSlice(flagWhere, nil, "Only select rules whose metadata matches field=value, e.g. label=pci (can be repeated)")
+}
+
+func getWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	_, sources, err := selectRules(ictx.GetConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	metadata := make([]project.RuleMetadata, 0, len(sources))
+	for _, s := range sources {
+		metadata = append(metadata, s.Metadata)
+	}
+	out, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stdout, string(out))
+	return []workflow.Data{}, nil
+}
+
+func setWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	config := ictx.GetConfiguration()
+	c, err := changesFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if c.empty() {
+		return nil, fmt.Errorf("nothing to change, use flags such as --%s or --%s", flagSeverity, flagAddLabel)
+	}
+	if len(config.GetStringSlice(flagWhere)) == 0 {
+		return nil, fmt.Errorf("--%s is required, e.g. --%s id=ACME-001", flagWhere, flagWhere)
+	}
+	prj, sources, err := selectRules(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no rules match the given selectors")
+	}
+	var updated []string
+	for _, s := range sources {
+		metadata := c.apply(s.Metadata)
+		if reflect.DeepEqual(metadata, s.Metadata) {
+			continue
+		}
+		if err := prj.UpdateRuleMetadata(s, metadata); err != nil {
+			return nil, err
+		}
+		updated = append(updated, s.Path)
+	}
+	if err := prj.WriteChanges(); err != nil {
+		return nil, err
+	}
+	for _, path := range updated {
+		fmt.Fprintf(os.Stderr, "Updated %s\n", path)
+	}
+	fmt.Fprintf(os.Stderr, "Updated the metadata of %d of %d matching rules.\n", len(updated), len(sources))
+	return []workflow.Data{}, nil
+}
+
+// selectRules returns the metadata of the rules that match the --where
+// selectors.
+func selectRules(config configuration.Configuration) (*project.Project, []project.RuleMetadataSource, error) {
+	conditions, err := parseConditions(config.GetStringSlice(flagWhere))
+	if err != nil {
+		return nil, nil, err
+	}
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, nil, err
+	}
+	sources, err := prj.RuleMetadataSources()
+	if err != nil {
+		return nil, nil, err
+	}
+	var selected []project.RuleMetadataSource
+	for _, s := range sources {
+		if matches(s.Metadata, conditions) {
+			selected = append(selected, s)
+		}
+	}
+	return prj, selected, nil
+}
+
+func changesFromConfig(config configuration.Configuration) (changes, error) {
+	var c changes
+	optional := func(flag string) *string {
+		if !config.IsSet(flag) {
+			return nil
+		}
+		value := config.GetString(flag)
+		return &value
+	}
+	c.severity = optional(flagSeverity)
+	c.title = optional(flagTitle)
+	c.description = optional(flagDescription)
+	c.category = optional(flagCategory)
+	c.serviceGroup = optional(flagServiceGroup)
+	if config.IsSet(flagProduct) {
+		c.product = []string{}
+		for _, p := range config.GetStringSlice(flagProduct) {
+			if p == "both" {
+				c.product = append(c.product, "iac", "cloud")
+			} else {
+				c.product = append(c.product, p)
+			}
+		}
+	}
+	if config.IsSet(flagPlatform) {
+		c.platform = config.GetStringSlice(flagPlatform)
+	}
+	c.addLabels = config.GetStringSlice(flagAddLabel)
+	c.removeLabels = config.GetStringSlice(flagRemoveLabel)
+
+	if c.severity != nil {
+		if err := forms.ValidateSeverity(*c.severity); err != nil {
+			return changes{}, err
+		}
+	}
+	for _, p := range c.product {
+		if err := forms.ValidateProduct(p); err != nil {
+			return changes{}, err
+		}
+	}
+	return c, nil
+}
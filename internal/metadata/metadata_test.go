@@ -0,0 +1,62 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangesFromConfigProduct(t *testing.T) {
+	testCases := []struct {
+		name          string
+		product       []string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:     "single product",
+			product:  []string{"cloud"},
+			expected: []string{"cloud"},
+		},
+		{
+			name:     "both products",
+			product:  []string{"both"},
+			expected: []string{"iac", "cloud"},
+		},
+		{
+			name:          "unknown product",
+			product:       []string{"kubernetes"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configuration.NewInMemory()
+			config.Set(flagProduct, tc.product)
+			c, err := changesFromConfig(config)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, c.product)
+		})
+	}
+}
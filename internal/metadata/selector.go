@@ -0,0 +1,157 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+// condition is a selector of the form field=value given with --where.
+type condition struct {
+	field string
+	value string
+}
+
+func parseConditions(where []string) ([]condition, error) {
+	conditions := make([]condition, 0, len(where))
+	for _, w := range where {
+		field, value, ok := strings.Cut(w, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid selector '%s', must be of the form field=value", w)
+		}
+		field = strings.TrimSpace(field)
+		if _, ok := fieldValues(project.RuleMetadata{}, field); !ok {
+			return nil, fmt.Errorf("invalid selector '%s', unknown field %s", w, field)
+		}
+		conditions = append(conditions, condition{field: field, value: strings.TrimSpace(value)})
+	}
+	return conditions, nil
+}
+
+// matches returns whether the metadata satisfies all conditions. A list field
+// such as labels satisfies a condition when it contains the value.
+func matches(metadata project.RuleMetadata, conditions []condition) bool {
+	for _, c := range conditions {
+		values, _ := fieldValues(metadata, c.field)
+		var found bool
+		for _, v := range values {
+			if v == c.value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldValues returns the values of a metadata field by its JSON name. Labels
+// can also be selected as "label", and "framework" selects the compliance
+// frameworks of the controls.
+func fieldValues(metadata project.RuleMetadata, field string) ([]string, bool) {
+	switch field {
+	case "id":
+		return []string{metadata.ID}, true
+	case "severity":
+		return []string{metadata.Severity}, true
+	case "title":
+		return []string{metadata.Title}, true
+	case "description":
+		return []string{metadata.Description}, true
+	case "product":
+		return metadata.Product, true
+	case "category":
+		return []string{metadata.Category}, true
+	case "label", "labels":
+		return metadata.Labels, true
+	case "platform":
+		return metadata.Platform, true
+	case "service_group":
+		return []string{metadata.ServiceGroup}, true
+	case "framework":
+		frameworks := make([]string, 0, len(metadata.Controls))
+		for f := range metadata.Controls {
+			frameworks = append(frameworks, f)
+		}
+		return frameworks, true
+	}
+	return nil, false
+}
+
+// changes are the updates to make to the metadata of the selected rules. Nil
+// fields are left as they are.
+type changes struct {
+	severity     *string
+	title        *string
+	description  *string
+	category     *string
+	serviceGroup *string
+	product      []string
+	platform     []string
+	addLabels    []string
+	removeLabels []string
+}
+
+func (c changes) empty() bool {
+	return c.severity == nil &&
+		c.title == nil &&
+		c.description == nil &&
+		c.category == nil &&
+		c.serviceGroup == nil &&
+		c.product == nil &&
+		c.platform == nil &&
+		len(c.addLabels) == 0 &&
+		len(c.removeLabels) == 0
+}
+
+func (c changes) apply(metadata project.RuleMetadata) project.RuleMetadata {
+	setString := func(field *string, value *string) {
+		if value != nil {
+			*field = *value
+		}
+	}
+	setString(&metadata.Severity, c.severity)
+	setString(&metadata.Title, c.title)
+	setString(&metadata.Description, c.description)
+	setString(&metadata.Category, c.category)
+	setString(&metadata.ServiceGroup, c.serviceGroup)
+	if c.product != nil {
+		metadata.Product = c.product
+	}
+	if c.platform != nil {
+		metadata.Platform = c.platform
+	}
+	if len(c.addLabels) > 0 || len(c.removeLabels) > 0 {
+		remove := map[string]bool{}
+		for _, l := range c.removeLabels {
+			remove[l] = true
+		}
+		var labels []string
+		seen := map[string]bool{}
+		for _, l := range append(append([]string{}, metadata.Labels...), c.addLabels...) {
+			if !remove[l] && !seen[l] {
+				seen[l] = true
+				labels = append(labels, l)
+			}
+		}
+		metadata.Labels = labels
+	}
+	return metadata
+}
@@ -0,0 +1,115 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+func TestMatches(t *testing.T) {
+	metadata := project.RuleMetadata{
+		ID:       "ACME-001",
+		Severity: "low",
+		Product:  []string{"iac"},
+		Labels:   []string{"pci", "storage"},
+		Controls: map[string]map[string][]string{
+			"CIS-AWS": {"v1.4.0": []string{"2.1.5"}},
+		},
+	}
+	testCases := []struct {
+		name     string
+		where    []string
+		expected bool
+	}{
+		{
+			name:     "no selectors",
+			expected: true,
+		},
+		{
+			name:     "scalar field",
+			where:    []string{"severity=low"},
+			expected: true,
+		},
+		{
+			name:     "list field contains value",
+			where:    []string{"label=pci"},
+			expected: true,
+		},
+		{
+			name:     "all selectors have to match",
+			where:    []string{"labels=storage", "severity=high"},
+			expected: false,
+		},
+		{
+			name:     "whitespace is ignored",
+			where:    []string{"id = ACME-001", "product= iac"},
+			expected: true,
+		},
+		{
+			name:     "compliance framework",
+			where:    []string{"framework=CIS-AWS"},
+			expected: true,
+		},
+		{
+			name:     "empty value",
+			where:    []string{"category="},
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conditions, err := parseConditions(tc.where)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, matches(metadata, conditions))
+		})
+	}
+
+	_, err := parseConditions([]string{"severity"})
+	assert.ErrorContains(t, err, "must be of the form field=value")
+	_, err = parseConditions([]string{"owner=me"})
+	assert.ErrorContains(t, err, "unknown field owner")
+}
+
+func TestChangesApply(t *testing.T) {
+	severity := "medium"
+	category := ""
+	c := changes{
+		severity:     &severity,
+		category:     &category,
+		addLabels:    []string{"hipaa", "pci"},
+		removeLabels: []string{"legacy"},
+	}
+	assert.False(t, c.empty())
+	assert.True(t, changes{}.empty())
+
+	updated := c.apply(project.RuleMetadata{
+		ID:       "ACME-001",
+		Severity: "low",
+		Category: "storage",
+		Product:  []string{"iac"},
+		Labels:   []string{"pci", "legacy"},
+	})
+	assert.Equal(t, project.RuleMetadata{
+		ID:       "ACME-001",
+		Severity: "medium",
+		Product:  []string{"iac"},
+		Labels:   []string{"pci", "hipaa"},
+	}, updated)
+}
@@ -0,0 +1,175 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNoPendingChange is returned when a file that is not going to be created
+// or modified is edited with UpdatePendingFile
+var ErrNoPendingChange = errors.New("no pending change for file")
+
+// ChangeKind describes what WriteChanges does to a file.
+type ChangeKind string
+
+const (
+	ChangeCreate ChangeKind = "create"
+	ChangeModify ChangeKind = "modify"
+	ChangeDelete ChangeKind = "delete"
+)
+
+// Change is a pending change to a file or, for deletions, a directory.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	// Before contains the current contents of a modified file.
+	Before []byte
+	// After contains the new contents of a created or modified file.
+	After []byte
+}
+
+// PendingChanges returns the changes that WriteChanges would make, sorted by
+// path. Files that would be rewritten with the same contents are left out,
+// and so are moves of directories and the push history.
+func (p *Project) PendingChanges() ([]Change, error) {
+	var changes []Change
+	for _, node := range p.stagedNodes() {
+		change, err := p.pendingChange(node)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	manifest, err := p.manifestFile.contents()
+	if err != nil {
+		return nil, err
+	}
+	manifestFile := NewFile(p.manifestFile.Path())
+	manifestFile.exists = p.manifestFile.Exists()
+	manifestFile.UpdateContents(manifest)
+	change, err := p.pendingChange(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		changes = append(changes, *change)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// UpdatePendingFile replaces the contents of a file that is going to be
+// created or modified, e.g. after the user has edited it.
+func (p *Project) UpdatePendingFile(path string, contents []byte) error {
+	if path == p.manifestFile.Path() {
+		var manifest Manifest
+		if err := json.Unmarshal(contents, &manifest); err != nil {
+			return pathError(path, ErrFailedToUnmarshalManifest, err)
+		}
+		p.manifestFile.UpdateContents(manifest)
+		return nil
+	}
+	for _, node := range p.stagedNodes() {
+		f, ok := node.(*File)
+		if !ok || f.Path() != path || f.removed || (f.exists && !f.dirty) {
+			continue
+		}
+		f.UpdateContents(contents)
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNoPendingChange, path)
+}
+
+// stagedNodes returns the nodes that WriteChanges may change, other than the
+// manifest and the push history.
+func (p *Project) stagedNodes() []FSNode {
+	var nodes []FSNode
+	for _, rule := range p.rulesDir.removed {
+		nodes = append(nodes, rule.Dir)
+	}
+	for _, rule := range p.rulesDir.rules {
+		for _, node := range rule.files {
+			nodes = append(nodes, node)
+		}
+	}
+	nodes = append(nodes, p.libDir.relations.File)
+	for _, specs := range p.specDir.removed {
+		nodes = append(nodes, specs.Dir)
+	}
+	for _, specs := range p.specDir.ruleSpecs {
+		fixtures := append([]*RuleSpec{}, specs.removed...)
+		for _, spec := range specs.fixtures {
+			fixtures = append(fixtures, spec)
+		}
+		for _, spec := range fixtures {
+			nodes = append(nodes, spec.Input)
+			if spec.Expected != nil {
+				nodes = append(nodes, spec.Expected)
+			}
+		}
+	}
+	for _, f := range p.pendingFiles {
+		nodes = append(nodes, f)
+	}
+	return nodes
+}
+
+func (p *Project) pendingChange(node FSNode) (*Change, error) {
+	switch n := node.(type) {
+	case *Dir:
+		if n.removed && n.exists {
+			return &Change{Path: n.path, Kind: ChangeDelete}, nil
+		}
+	case *File:
+		if n.removed {
+			if !n.exists {
+				return nil, nil
+			}
+			return &Change{Path: n.path, Kind: ChangeDelete}, nil
+		}
+		if n.exists && !n.dirty {
+			return nil, nil
+		}
+		change := &Change{
+			Path:  n.path,
+			Kind:  ChangeCreate,
+			After: n.pendingContents,
+		}
+		if n.exists {
+			before, err := afero.ReadFile(p.FS, n.path)
+			if err != nil {
+				return nil, readPathError(n.path, err)
+			}
+			if bytes.Equal(before, n.pendingContents) {
+				return nil, nil
+			}
+			change.Kind = ChangeModify
+			change.Before = before
+		}
+		return change, nil
+	}
+	return nil, nil
+}
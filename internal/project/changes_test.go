@@ -0,0 +1,91 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectPendingChanges(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"manifest.json":                    "{\n  \"name\": \"test\"\n}",
+		"lib/relations.rego":               "package relations\n\nimport data.snyk\n",
+		"rules/OLD/main.rego":              "package rules.OLD\n",
+		"spec/rules/OLD/inputs/infra.tf":   `resource "aws_s3_bucket" "b" {}`,
+		"spec/rules/OTHER/inputs/infra.tf": `resource "aws_s3_bucket" "b" {}`,
+	}
+	for path, contents := range files {
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(contents), 0644))
+	}
+	prj, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+
+	changes, err := prj.PendingChanges()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = prj.AddRule("ACME-001", "main.rego", []byte("package rules.ACME_001\n"))
+	require.NoError(t, err)
+	_, err = prj.AddRuleSpec("ACME-001", "infra.tf", []byte(`resource "aws_s3_bucket" "b" {}`))
+	require.NoError(t, err)
+	_, err = prj.AddRelation(`relations[info] {
+	info := {"name": "acl"}
+}`)
+	require.NoError(t, err)
+	_, err = prj.RemoveRuleSpec("OTHER", "infra")
+	require.NoError(t, err)
+
+	changes, err = prj.PendingChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	assert.Equal(t, Change{
+		Path:   "lib/relations.rego",
+		Kind:   ChangeModify,
+		Before: []byte("package relations\n\nimport data.snyk\n"),
+		After:  []byte("package relations\n\nimport data.snyk\n\nrelations[info] {\n\tinfo := {\"name\": \"acl\"}\n}\n"),
+	}, changes[0])
+	assert.Equal(t, Change{
+		Path:  "rules/ACME_001/main.rego",
+		Kind:  ChangeCreate,
+		After: []byte("package rules.ACME_001\n"),
+	}, changes[1])
+	assert.Equal(t, "spec/rules/ACME_001/inputs/infra.tf", changes[2].Path)
+	assert.Equal(t, ChangeCreate, changes[2].Kind)
+	assert.Equal(t, Change{
+		Path: "spec/rules/OTHER/inputs/infra.tf",
+		Kind: ChangeDelete,
+	}, changes[3])
+
+	err = prj.UpdatePendingFile("rules/OLD/main.rego", []byte("package rules.NEW\n"))
+	assert.ErrorIs(t, err, ErrNoPendingChange)
+	require.NoError(t, prj.UpdatePendingFile("rules/ACME_001/main.rego", []byte("package rules.EDITED\n")))
+	require.NoError(t, prj.UpdatePendingFile("manifest.json", []byte(`{"name": "edited"}`)))
+	changes, err = prj.PendingChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+	assert.Equal(t, "manifest.json", changes[1].Path)
+	assert.Equal(t, ChangeModify, changes[1].Kind)
+
+	require.NoError(t, prj.WriteChanges())
+	contents, err := afero.ReadFile(fsys, "rules/ACME_001/main.rego")
+	require.NoError(t, err)
+	assert.Equal(t, "package rules.EDITED\n", string(contents))
+	assert.Equal(t, "edited", prj.Manifest().Name)
+}
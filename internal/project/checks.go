@@ -0,0 +1,199 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/afero"
+)
+
+const (
+	// DefaultMaxFileSize is the default limit for a single bundled file.
+	DefaultMaxFileSize int64 = 1 << 20
+	// DefaultMaxBundleSize is the default limit for all bundled files
+	// combined.
+	DefaultMaxBundleSize int64 = 10 << 20
+)
+
+// fixtureExtensions are the extensions of IaC files that usually belong in
+// spec/ rather than in the bundle.
+var fixtureExtensions = map[string]bool{
+	".tf":     true,
+	".hcl":    true,
+	".json":   true,
+	".yaml":   true,
+	".yml":    true,
+	".tfvars": true,
+}
+
+// BundleProblem describes a file that should not be pushed as part of the rule
+// bundle. Warnings do not prevent pushing.
+type BundleProblem struct {
+	Path    string
+	Message string
+	Warning bool
+}
+
+func (p BundleProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// CheckBundleContents checks the files in the lib and rules directories before
+// they are bundled. It reports files and bundles over the size limits,
+// non-rego files, which are left out of the bundle, duplicate packages and
+// rules without remediation, references or controls, with paths relative to
+// the project directory.
+func (p *Project) CheckBundleContents() ([]BundleProblem, error) {
+	maxFileSize, maxBundleSize := DefaultMaxFileSize, DefaultMaxBundleSize
+	if limits := p.Manifest().BundleLimits; limits != nil {
+		if limits.MaxFileSize > 0 {
+			maxFileSize = limits.MaxFileSize
+		}
+		if limits.MaxBundleSize > 0 {
+			maxBundleSize = limits.MaxBundleSize
+		}
+	}
+
+	files, err := p.bundleFiles()
+	if err != nil {
+		return nil, err
+	}
+	var problems []BundleProblem
+	var total int64
+	// Maps packages to the directories of the files that declare them.
+	packages := map[string]map[string]bool{}
+	for _, path := range files {
+		rel, err := filepath.Rel(p.Path(), path)
+		if err != nil {
+			rel = path
+		}
+		report := func(warning bool, format string, args ...interface{}) {
+			problems = append(problems, BundleProblem{
+				Path:    rel,
+				Message: fmt.Sprintf(format, args...),
+				Warning: warning,
+			})
+		}
+
+		if !bundled(path) {
+			// Rego tests are expected next to the rules and are left out
+			// silently.
+			if ext := filepath.Ext(path); ext != ".rego" {
+				if fixtureExtensions[ext] {
+					report(true, "looks like a spec fixture and is left out of the bundle, move it to the spec directory")
+				} else {
+					report(true, "is not a rego file and is left out of the bundle")
+				}
+			}
+			continue
+		}
+
+		info, err := p.FS.Stat(path)
+		if err != nil {
+			return nil, readPathError(path, err)
+		}
+		total += info.Size()
+		if info.Size() > maxFileSize {
+			report(false, "file is %s, which exceeds the limit of %s", formatSize(info.Size()), formatSize(maxFileSize))
+		}
+
+		contents, err := afero.ReadFile(p.FS, path)
+		if err != nil {
+			return nil, readPathError(path, err)
+		}
+		module, err := ast.ParseModule(path, string(contents))
+		if err != nil || module == nil {
+			// Syntax errors are reported when the bundle is built.
+			continue
+		}
+		if _, ok := rulePackage(module); ok {
+			// Metadata that isn't a literal is checked by the engine.
+			if rule, metadata, err := decodeMetadata(module); err == nil && rule != nil {
+				if missing := metadata.MissingAuditFields(); len(missing) > 0 {
+					report(true, "rule %s has no %s in its metadata", metadata.ID, joinFields(missing))
+				}
+			}
+		}
+		pkg := module.Package.Path.String()
+		if packages[pkg] == nil {
+			packages[pkg] = map[string]bool{}
+		}
+		packages[pkg][filepath.Dir(rel)] = true
+	}
+
+	if total > maxBundleSize {
+		problems = append(problems, BundleProblem{
+			Path:    ".",
+			Message: fmt.Sprintf("bundled files total %s, which exceeds the limit of %s", formatSize(total), formatSize(maxBundleSize)),
+		})
+	}
+
+	// Splitting a package across files in one directory is fine, but the same
+	// package in different directories usually means a rule was copied without
+	// renaming it.
+	for pkg, dirs := range packages {
+		if len(dirs) < 2 {
+			continue
+		}
+		sorted := make([]string, 0, len(dirs))
+		for dir := range dirs {
+			sorted = append(sorted, dir)
+		}
+		sort.Strings(sorted)
+		for _, dir := range sorted {
+			problems = append(problems, BundleProblem{
+				Path:    dir,
+				Message: fmt.Sprintf("package %s is also declared in %s", strings.TrimPrefix(pkg, "data."), strings.Join(others(sorted, dir), ", ")),
+			})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+// joinFields joins field names into a list such as "a, b or c".
+func joinFields(fields []string) string {
+	if len(fields) < 2 {
+		return strings.Join(fields, "")
+	}
+	return strings.Join(fields[:len(fields)-1], ", ") + " or " + fields[len(fields)-1]
+}
+
+func others(values []string, value string) []string {
+	var out []string
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
@@ -0,0 +1,132 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuditedRule is testRule with remediation, references and controls.
+var testAuditedRule = bytes.Replace(testRule, []byte(`	"product": [`), []byte(`	"remediation": {"tf": "Rename the bucket"},
+	"references": {"general": [{"url": "https://example.com/buckets"}]},
+	"controls": {"ACME": {"v1": ["1.1"]}},
+	"product": [`), 1)
+
+func TestProjectCheckBundleContents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		files    map[string][]byte
+		expected []BundleProblem
+	}{
+		{
+			name: "clean project",
+			files: map[string][]byte{
+				"lib/relations.rego":          testRelationsFile,
+				"rules/TEST_001/main.rego":    testAuditedRule,
+				"rules/TEST_001/helpers.rego": []byte("package rules.TEST_001\n"),
+			},
+		},
+		{
+			name: "non-rego files and fixtures",
+			files: map[string][]byte{
+				"rules/TEST_001/main.rego": testAuditedRule,
+				"rules/TEST_001/NOTES.md":  []byte("notes"),
+				"rules/TEST_001/main.tf":   []byte(`resource "aws_s3_bucket" "b" {}`),
+			},
+			expected: []BundleProblem{
+				{Path: "rules/TEST_001/NOTES.md", Message: "is not a rego file and is left out of the bundle", Warning: true},
+				{
+					Path:    "rules/TEST_001/main.tf",
+					Message: "looks like a spec fixture and is left out of the bundle, move it to the spec directory",
+					Warning: true,
+				},
+			},
+		},
+		{
+			name: "test files",
+			files: map[string][]byte{
+				"rules/TEST_001/main.rego":      testAuditedRule,
+				"rules/TEST_001/main_test.rego": append([]byte("package rules.TEST_001\n"), bytes.Repeat([]byte("#"), 2<<20)...),
+			},
+		},
+		{
+			name: "duplicate packages",
+			files: map[string][]byte{
+				"rules/TEST_001/main.rego": testAuditedRule,
+				"rules/TEST_002/main.rego": testAuditedRule,
+			},
+			expected: []BundleProblem{
+				{Path: "rules/TEST_001", Message: "package rules.TEST_001 is also declared in rules/TEST_002"},
+				{Path: "rules/TEST_002", Message: "package rules.TEST_001 is also declared in rules/TEST_001"},
+			},
+		},
+		{
+			name: "rules without remediation, references or controls",
+			files: map[string][]byte{
+				"rules/TEST_001/main.rego": testRule,
+				"rules/TEST_002/main.rego": bytes.Replace(
+					bytes.Replace(testAuditedRule, []byte("TEST_001"), []byte("TEST_002"), 1),
+					[]byte(`	"controls": {"ACME": {"v1": ["1.1"]}},
+`), nil, 1),
+			},
+			expected: []BundleProblem{
+				{
+					Path:    "rules/TEST_001/main.rego",
+					Message: "rule TEST-001 has no remediation, references or controls in its metadata",
+					Warning: true,
+				},
+				{
+					Path:    "rules/TEST_002/main.rego",
+					Message: "rule TEST-001 has no controls in its metadata",
+					Warning: true,
+				},
+			},
+		},
+		{
+			name:     "size limits from the manifest",
+			manifest: `{"name":"test","bundle_limits":{"max_file_size":2048,"max_bundle_size":4096}}`,
+			files: map[string][]byte{
+				"lib/big.rego":   append([]byte("package big\n"), bytes.Repeat([]byte("#"), 4096)...),
+				"lib/small.rego": append([]byte("package small\n"), bytes.Repeat([]byte("#"), 1024)...),
+			},
+			expected: []BundleProblem{
+				{Path: ".", Message: "bundled files total 5.0 KiB, which exceeds the limit of 4.0 KiB"},
+				{Path: "lib/big.rego", Message: "file is 4.0 KiB, which exceeds the limit of 2.0 KiB"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+			if tc.manifest != "" {
+				afero.WriteFile(fsys, "root/manifest.json", []byte(tc.manifest), 0644)
+			}
+			for path, contents := range tc.files {
+				afero.WriteFile(fsys, "root/"+path, contents, 0644)
+			}
+			p, err := FromDir(fsys, "root")
+			require.NoError(t, err)
+			problems, err := p.CheckBundleContents()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, problems)
+		})
+	}
+}
@@ -34,6 +34,13 @@ var ErrFailedToCreateDir = errors.New("failed to create directory")
 // ErrFailedToCreateFile is returned when we were unable to create a file
 var ErrFailedToCreateFile = errors.New("failed to write to file")
 
+// ErrFailedToRemovePath is returned when we were unable to remove a file or
+// directory
+var ErrFailedToRemovePath = errors.New("failed to remove path")
+
+// ErrFailedToMoveDir is returned when we were unable to move a directory
+var ErrFailedToMoveDir = errors.New("failed to move directory")
+
 // ErrFailedToReadPath is returned when we encountered a filesystem error while
 // reading a path.
 var ErrFailedToReadPath = errors.New("failed to read path")
@@ -64,6 +71,9 @@ type FSNode interface {
 	IsDir() bool
 	// WriteChanges persists any changes to this node back to disk.
 	WriteChanges(fsys afero.Fs) error
+	// Remove stages the removal of this node, which happens when WriteChanges
+	// is called.
+	Remove()
 }
 
 // FSNodeFromFileInfo returns an FSNode for the given fs.FileInfo object in the
@@ -81,6 +91,7 @@ type File struct {
 	path            string
 	exists          bool
 	dirty           bool
+	removed         bool
 	pendingContents []byte
 }
 
@@ -138,8 +149,22 @@ func (f *File) UpdateContents(b []byte) {
 	f.dirty = true
 }
 
+// Remove stages the removal of this file.
+func (f *File) Remove() {
+	f.removed = true
+	f.dirty = false
+	f.pendingContents = nil
+}
+
 // WriteChanges persists any changes to this file to disk.
 func (f *File) WriteChanges(fsys afero.Fs) error {
+	if f.removed {
+		if err := removePath(fsys, f.path, f.exists); err != nil {
+			return err
+		}
+		f.exists = false
+		return nil
+	}
 	if f.exists && !f.dirty {
 		return nil
 	}
@@ -160,10 +185,28 @@ func (f *File) WriteChanges(fsys afero.Fs) error {
 	return nil
 }
 
+// movedNode returns the node at its new path, after the directory that
+// contains it has been moved. Nodes that don't exist yet still don't.
+func movedNode(node FSNode, path string) FSNode {
+	switch {
+	case node.IsDir() && node.Exists():
+		return ExistingDir(path)
+	case node.IsDir():
+		return NewDir(path)
+	case node.Exists():
+		return ExistingFile(path)
+	}
+	return NewFile(path)
+}
+
 // Dir represents a directory on disk.
 type Dir struct {
 	path   string
 	exists bool
+	// movedFrom is the existing directory that is moved to this directory's
+	// path when changes are written.
+	movedFrom string
+	removed   bool
 }
 
 // NewDir returns a Dir object that represents a directory that does not exist
@@ -184,6 +227,16 @@ func ExistingDir(path string) *Dir {
 	}
 }
 
+// MovedDir returns a Dir object that represents an existing directory that
+// will be moved to a new path.
+func MovedDir(from string, to string) *Dir {
+	return &Dir{
+		path:      to,
+		exists:    false,
+		movedFrom: from,
+	}
+}
+
 // DirFromPath returns a Dir for the given path whether it exists or not.
 func DirFromPath(fsys afero.Fs, path string) (*Dir, error) {
 	info, err := fsys.Stat(path)
@@ -215,11 +268,36 @@ func (d *Dir) IsDir() bool {
 	return true
 }
 
-// WriteChanges will create the directory on disk if it does not already exist.
+// Remove stages the removal of this directory and everything in it.
+func (d *Dir) Remove() {
+	d.removed = true
+}
+
+// WriteChanges will create the directory on disk if it does not already exist,
+// or move it into place if it was moved. A removed directory is deleted
+// along with its contents.
 func (d *Dir) WriteChanges(fsys afero.Fs) error {
+	if d.removed {
+		if err := removePath(fsys, d.path, d.exists); err != nil {
+			return err
+		}
+		d.exists = false
+		return nil
+	}
 	if d.exists {
 		return nil
 	}
+	if d.movedFrom != "" {
+		if err := fsys.MkdirAll(filepath.Dir(d.path), directoryPermission); err != nil {
+			return pathError(d.path, ErrFailedToCreateDir, err)
+		}
+		if err := fsys.Rename(d.movedFrom, d.path); err != nil {
+			return pathError(d.path, ErrFailedToMoveDir, err)
+		}
+		d.exists = true
+		d.movedFrom = ""
+		return nil
+	}
 	if err := fsys.MkdirAll(d.path, directoryPermission); err != nil {
 		return pathError(d.path, ErrFailedToCreateDir, err)
 	}
@@ -227,6 +305,16 @@ func (d *Dir) WriteChanges(fsys afero.Fs) error {
 	return nil
 }
 
+func removePath(fsys afero.Fs, path string, exists bool) error {
+	if !exists {
+		return nil
+	}
+	if err := fsys.RemoveAll(path); err != nil {
+		return pathError(path, ErrFailedToRemovePath, err)
+	}
+	return nil
+}
+
 var replaceCharsRegex = regexp.MustCompile(`[^0-9A-Za-z_.]`)
 var validIdentifier = regexp.MustCompile(`^[[:alpha:]]`)
 
@@ -0,0 +1,146 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Excluded returns true if the given path matches one of the exclude patterns
+// from the manifest, or is inside a directory that does. Patterns are
+// slash-separated and relative to the project directory. A "**" segment
+// matches any number of directories, and patterns without a slash match file
+// or directory names at any depth, e.g. "*.md".
+func (p *Project) Excluded(filePath string) bool {
+	patterns := p.manifestFile.manifest.Exclude
+	if len(patterns) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(p.Path(), filePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return excluded(patterns, filepath.ToSlash(rel))
+}
+
+func excluded(patterns []string, rel string) bool {
+	segments := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		patternSegments := strings.Split(pattern, "/")
+		if len(patternSegments) == 1 {
+			// Match the name of the file or any of its parents.
+			patternSegments = []string{"**", pattern}
+		}
+		// Matching any prefix of the path excludes everything below it.
+		for i := 1; i <= len(segments); i++ {
+			if matchSegments(patternSegments, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// excludeFS hides excluded files from an fs.FS so that rego providers see the
+// same files that are bundled.
+type excludeFS struct {
+	fs.FS
+	excluded func(name string) bool
+}
+
+func (e excludeFS) Open(name string) (fs.File, error) {
+	if e.excluded(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := e.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok {
+		return &excludeDir{ReadDirFile: dir, fs: e, name: name}, nil
+	}
+	return f, nil
+}
+
+func (e excludeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if e.excluded(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries, err := fs.ReadDir(e.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	return e.filter(name, entries), nil
+}
+
+func (e excludeFS) Stat(name string) (fs.FileInfo, error) {
+	if e.excluded(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(e.FS, name)
+}
+
+func (e excludeFS) filter(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	filtered := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !e.excluded(path.Join(dir, entry.Name())) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+type excludeDir struct {
+	fs.ReadDirFile
+	fs   excludeFS
+	name string
+}
+
+func (d *excludeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	return d.fs.filter(d.name, entries), err
+}
@@ -0,0 +1,121 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcluded(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: "rules/TEST_001/scratch.rego", path: "rules/TEST_001/scratch.rego", expected: true},
+		{pattern: "rules/TEST_001/scratch.rego", path: "rules/TEST_001/main.rego", expected: false},
+		{pattern: "rules/EXPERIMENTAL_*", path: "rules/EXPERIMENTAL_001/main.rego", expected: true},
+		{pattern: "rules/EXPERIMENTAL_*", path: "rules/TEST_001/main.rego", expected: false},
+		{pattern: "*.md", path: "rules/TEST_001/README.md", expected: true},
+		{pattern: "*.md", path: "README.md", expected: true},
+		{pattern: "docs", path: "lib/docs/index.rego", expected: true},
+		{pattern: "lib/**/*_test.rego", path: "lib/main_test.rego", expected: true},
+		{pattern: "lib/**/*_test.rego", path: "lib/a/b/main_test.rego", expected: true},
+		{pattern: "lib/**/*_test.rego", path: "rules/TEST_001/main_test.rego", expected: false},
+		{pattern: "**/inputs/*.tf", path: "spec/rules/TEST_001/inputs/infra.tf", expected: true},
+		{pattern: "/rules/TEST_002/", path: "rules/TEST_002/main.rego", expected: true},
+		{pattern: "[", path: "rules/TEST_001/main.rego", expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, excluded([]string{tc.pattern}, tc.path))
+		})
+	}
+}
+
+func testExcludeProject(t *testing.T) *Project {
+	fsys := afero.NewMemMapFs()
+	afero.WriteFile(fsys, "root/manifest.json", []byte(`{"name":"test","exclude":["rules/EXPERIMENTAL_*","*.md"]}`), 0644)
+	afero.WriteFile(fsys, "root/lib/relations.rego", testRelationsFile, 0644)
+	afero.WriteFile(fsys, "root/lib/README.md", []byte("docs"), 0644)
+	afero.WriteFile(fsys, "root/rules/TEST_001/main.rego", testRule, 0644)
+	afero.WriteFile(fsys, "root/rules/EXPERIMENTAL_001/main.rego", []byte("package rules.EXPERIMENTAL_001\n"), 0644)
+	afero.WriteFile(fsys, "root/spec/rules/TEST_001/inputs/infra.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "root/spec/rules/EXPERIMENTAL_001/inputs/infra.tf", []byte{}, 0644)
+	p, err := FromDir(fsys, "root")
+	require.NoError(t, err)
+	return p
+}
+
+func TestProjectExclude(t *testing.T) {
+	p := testExcludeProject(t)
+
+	files, err := p.RegoFiles()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"root/lib/relations.rego", "root/rules/TEST_001/main.rego"}, files)
+
+	var specs []string
+	for _, spec := range p.RuleSpecs() {
+		specs = append(specs, spec.Input.Path())
+	}
+	assert.Equal(t, []string{"root/spec/rules/TEST_001/inputs/infra.tf"}, specs)
+
+	// Rego tests and other files next to the rules are not bundled either.
+	afero.WriteFile(p.FS, "root/rules/TEST_001/main_test.rego", []byte("package rules.TEST_001\n"), 0644)
+	afero.WriteFile(p.FS, "root/rules/TEST_001/fixture.json", []byte("{}"), 0644)
+	staged := afero.NewMemMapFs()
+	require.NoError(t, p.CopyBundleFiles(staged))
+	var copied []string
+	afero.Walk(staged, "", func(path string, info fs.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			copied = append(copied, path)
+		}
+		return nil
+	})
+	assert.ElementsMatch(t, []string{"manifest.json", "lib/relations.rego", "rules/TEST_001/main.rego"}, copied)
+}
+
+func TestExcludeFS(t *testing.T) {
+	p := testExcludeProject(t)
+	fsys := excludeFS{
+		FS: afero.NewIOFS(p.FS),
+		excluded: func(name string) bool {
+			return p.Excluded(name)
+		},
+	}
+
+	var walked []string
+	err := fs.WalkDir(fsys, "root/rules", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root/rules/TEST_001/main.rego"}, walked)
+
+	_, err = fsys.Open("root/lib/README.md")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fs.Stat(fsys, "root/rules/EXPERIMENTAL_001")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
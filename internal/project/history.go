@@ -0,0 +1,196 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ErrFailedToUnmarshalHistory is returned when we were unable to unmarshal the
+// push history from JSON
+var ErrFailedToUnmarshalHistory = errors.New("failed to unmarshal push history")
+
+// ErrArchiveNotCached is returned when a bundle archive is not present in the
+// local archive cache.
+var ErrArchiveNotCached = errors.New("bundle archive not found in local cache")
+
+const digestPrefix = "sha256:"
+
+// HistoryEntry records a single successful push of a rule bundle.
+type HistoryEntry struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Digest         string    `json:"digest"`
+	GitCommit      string    `json:"git_commit,omitempty"`
+	OrganizationID string    `json:"organization_id"`
+	CustomRulesID  string    `json:"custom_rules_id"`
+}
+
+// ContentDigest returns the digest of the files in a bundle archive, given by
+// their paths. Unlike a digest of the archive itself, it does not depend on
+// the order of the files, their timestamps or the compression, so bundles
+// built from the same sources have the same digest.
+func ContentDigest(files map[string][]byte) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		sum := sha256.Sum256(files[path])
+		fmt.Fprintf(h, "%s\x00%s\n", path, hex.EncodeToString(sum[:]))
+	}
+	return digestPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// MatchesDigest returns whether the given digest, or a prefix of it, refers to
+// this entry. The "sha256:" prefix is optional.
+func (e HistoryEntry) MatchesDigest(digest string) bool {
+	digest = strings.TrimPrefix(digest, digestPrefix)
+	return digest != "" && strings.HasPrefix(strings.TrimPrefix(e.Digest, digestPrefix), digest)
+}
+
+type historyFile struct {
+	*File
+	entries []HistoryEntry
+}
+
+func (h *historyFile) WriteChanges(fsys afero.Fs) error {
+	if !h.dirty {
+		return nil
+	}
+	b, err := json.MarshalIndent(h.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	h.File.UpdateContents(b)
+	return h.File.WriteChanges(fsys)
+}
+
+func (h *historyFile) addEntry(entry HistoryEntry) {
+	h.entries = append(h.entries, entry)
+	// Mark the file dirty so that WriteChanges picks up the new entry.
+	h.dirty = true
+}
+
+func historyFromDir(fsys afero.Fs, root string) (*historyFile, error) {
+	path := filepath.Join(root, "history.json")
+	file, err := FileFromPath(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	if !file.Exists() {
+		return &historyFile{File: file}, nil
+	}
+	b, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil, readPathError(path, err)
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, pathError(path, ErrFailedToUnmarshalHistory, err)
+	}
+	h := &historyFile{
+		File:    file,
+		entries: entries,
+	}
+	return h, nil
+}
+
+// archivesDir is a local cache of previously pushed bundle archives and their
+// signatures, keyed by file name.
+type archivesDir struct {
+	*Dir
+	files map[string]*File
+}
+
+func (a *archivesDir) WriteChanges(fsys afero.Fs) error {
+	if len(a.files) == 0 {
+		return nil
+	}
+	if err := a.Dir.WriteChanges(fsys); err != nil {
+		return err
+	}
+	for _, f := range a.files {
+		if err := f.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func archiveName(digest string) string {
+	return fmt.Sprintf("%s.tar.gz", strings.TrimPrefix(digest, digestPrefix))
+}
+
+func (a *archivesDir) addFile(name string, contents []byte) string {
+	file := NewFile(filepath.Join(a.Path(), name))
+	file.UpdateContents(contents)
+	a.files[name] = file
+	return file.Path()
+}
+
+func (a *archivesDir) hasFile(fsys afero.Fs, name string) (bool, error) {
+	if _, ok := a.files[name]; ok {
+		return true, nil
+	}
+	path := filepath.Join(a.Path(), name)
+	exists, err := afero.Exists(fsys, path)
+	if err != nil {
+		return false, readPathError(path, err)
+	}
+	return exists, nil
+}
+
+func (a *archivesDir) readFile(fsys afero.Fs, name string) ([]byte, error) {
+	if file, ok := a.files[name]; ok && file.dirty {
+		return file.pendingContents, nil
+	}
+	path := filepath.Join(a.Path(), name)
+	exists, err := afero.Exists(fsys, path)
+	if err != nil {
+		return nil, readPathError(path, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveNotCached, name)
+	}
+	b, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil, readPathError(path, err)
+	}
+	return b, nil
+}
+
+func archivesFromDir(fsys afero.Fs, root string) (*archivesDir, error) {
+	dir, err := DirFromPath(fsys, filepath.Join(root, ".bundles"))
+	if err != nil {
+		return nil, err
+	}
+	a := &archivesDir{
+		Dir:   dir,
+		files: map[string]*File{},
+	}
+	return a, nil
+}
@@ -0,0 +1,170 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryFromDir(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.Mkdir("empty", 0755)
+	fsys.Mkdir("existing", 0755)
+	fsys.Mkdir("error", 0755)
+	afero.WriteFile(fsys, "existing/history.json", []byte(`[{
+		"timestamp": "2023-06-01T12:00:00Z",
+		"digest": "sha256:abc",
+		"organization_id": "org-1",
+		"custom_rules_id": "bundle-1"
+	}]`), 0644)
+	afero.WriteFile(fsys, "error/history.json", []byte(`{}`), 0644)
+
+	testCases := []struct {
+		name          string
+		root          string
+		expected      *historyFile
+		expectedError error
+	}{
+		{
+			name: "from existing history file",
+			root: "existing",
+			expected: &historyFile{
+				File: ExistingFile("existing/history.json"),
+				entries: []HistoryEntry{
+					{
+						Timestamp:      time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+						Digest:         "sha256:abc",
+						OrganizationID: "org-1",
+						CustomRulesID:  "bundle-1",
+					},
+				},
+			},
+		},
+		{
+			name: "non-existing history file",
+			root: "empty",
+			expected: &historyFile{
+				File: NewFile("empty/history.json"),
+			},
+		},
+		{
+			name:          "invalid history file",
+			root:          "error",
+			expectedError: ErrFailedToUnmarshalHistory,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := historyFromDir(fsys, tc.root)
+			if tc.expectedError != nil {
+				assert.Nil(t, h)
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, h)
+			}
+		})
+	}
+}
+
+func TestProjectHistory(t *testing.T) {
+	t.Run("does not create history without pushes", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		p, err := FromDir(fsys, "new")
+		assert.NoError(t, err)
+		err = p.WriteChanges()
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fsys, "new/history.json")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		exists, err = afero.Exists(fsys, "new/.bundles")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("records pushes and caches archives", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		p, err := FromDir(fsys, "new")
+		assert.NoError(t, err)
+
+		archive := []byte("archive")
+		digest := ContentDigest(map[string][]byte{"rules/main.rego": archive})
+		entry := HistoryEntry{
+			Timestamp:      time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+			Digest:         digest,
+			OrganizationID: "org-1",
+			CustomRulesID:  "bundle-1",
+		}
+		p.RecordPush(entry)
+		path := p.CacheArchive(digest, archive)
+		assert.Equal(t, "new/.bundles/"+digest[len("sha256:"):]+".tar.gz", path)
+		sigPath := p.CacheArchiveSignature(digest, []byte("signature"))
+		assert.Equal(t, path+".sig", sigPath)
+		err = p.WriteChanges()
+		assert.NoError(t, err)
+
+		updated, err := FromDir(fsys, "new")
+		assert.NoError(t, err)
+		assert.Equal(t, []HistoryEntry{entry}, updated.History())
+		cached, err := updated.CachedArchive(digest)
+		assert.NoError(t, err)
+		assert.Equal(t, archive, cached)
+		signature, err := afero.ReadFile(fsys, sigPath)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("signature"), signature)
+		isCached, err := updated.HasCachedArchive(digest)
+		assert.NoError(t, err)
+		assert.True(t, isCached)
+		other := ContentDigest(map[string][]byte{"rules/main.rego": []byte("other")})
+		_, err = updated.CachedArchive(other)
+		assert.ErrorIs(t, err, ErrArchiveNotCached)
+		isCached, err = updated.HasCachedArchive(other)
+		assert.NoError(t, err)
+		assert.False(t, isCached)
+	})
+}
+
+func TestContentDigest(t *testing.T) {
+	files := map[string][]byte{
+		"rules/TEST_001/main.rego": []byte("package rules.TEST_001\n"),
+		"lib/relations.rego":       []byte("package relations\n"),
+	}
+	digest := ContentDigest(files)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", digest)
+	assert.Equal(t, digest, ContentDigest(map[string][]byte{
+		"lib/relations.rego":       []byte("package relations\n"),
+		"rules/TEST_001/main.rego": []byte("package rules.TEST_001\n"),
+	}))
+	assert.NotEqual(t, digest, ContentDigest(map[string][]byte{
+		"rules/TEST_002/main.rego": []byte("package rules.TEST_001\n"),
+		"lib/relations.rego":       []byte("package relations\n"),
+	}))
+	assert.NotEqual(t, digest, ContentDigest(map[string][]byte{
+		"rules/TEST_001/main.rego": []byte("package rules.TEST_001\n"),
+	}))
+}
+
+func TestHistoryEntryMatchesDigest(t *testing.T) {
+	entry := HistoryEntry{Digest: "sha256:0123456789abcdef"}
+	assert.True(t, entry.MatchesDigest("sha256:0123456789abcdef"))
+	assert.True(t, entry.MatchesDigest("0123456789abcdef"))
+	assert.True(t, entry.MatchesDigest("012345"))
+	assert.False(t, entry.MatchesDigest("abcdef"))
+	assert.False(t, entry.MatchesDigest(""))
+}
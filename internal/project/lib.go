@@ -15,8 +15,8 @@
 package project
 
 import (
-	"bytes"
 	"errors"
+	"fmt"
 	"path/filepath"
 
 	"github.com/open-policy-agent/opa/ast"
@@ -66,7 +66,6 @@ func libFromDir(fsys afero.Fs, root string) (*libDir, error) {
 type relationsFile struct {
 	*File
 	module *ast.Module
-	lines  int
 }
 
 const relationsStub = `package relations
@@ -110,18 +109,25 @@ func relationsFileFromDir(fsys afero.Fs, parent string) (*relationsFile, error)
 	r := &relationsFile{
 		File:   file,
 		module: module,
-		lines:  bytes.Count(contents, []byte{'\n'}),
 	}
 	return r, nil
 }
 
+// addRelation appends the given relation rule. It is parsed along with the
+// existing relations so that the comments of both are kept.
 func (r *relationsFile) addRelation(contents string) (string, error) {
-	rule, err := ast.ParseRule(contents)
+	current, err := format.Ast(r.module)
 	if err != nil {
 		return "", err
 	}
-	rule.Location.Row = r.lines + 1
-	r.module.Rules = append(r.module.Rules, rule)
+	module, err := ast.ParseModule(r.Path(), string(current)+"\n"+contents)
+	if err != nil {
+		return "", err
+	}
+	if len(module.Rules) != len(r.module.Rules)+1 {
+		return "", fmt.Errorf("expected a single relation rule, got %d", len(module.Rules)-len(r.module.Rules))
+	}
+	r.module = module
 	if err := r.UpdateContents(); err != nil {
 		return "", err
 	}
@@ -134,6 +140,5 @@ func (r *relationsFile) UpdateContents() error {
 		return err
 	}
 	r.File.UpdateContents(formatted)
-	r.lines = bytes.Count(formatted, []byte{'\n'})
 	return nil
 }
@@ -43,7 +43,6 @@ func TestLibFromDir(t *testing.T) {
 				Dir: ExistingDir("existing/lib"),
 				relations: &relationsFile{
 					module: expectedModule,
-					lines:  3,
 					File:   ExistingFile("existing/lib/relations.rego"),
 				},
 			},
@@ -97,3 +96,41 @@ func TestLibWriteChanges(t *testing.T) {
 		assert.True(t, relationsExists)
 	})
 }
+
+func TestRelationsFileAddRelation(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	afero.WriteFile(fsys, "lib/relations.rego", []byte(`package relations
+
+import data.snyk
+
+# Buckets and their ACLs
+relations[info] {
+	info := {"name": "acl"}
+}
+`), 0644)
+	r, err := relationsFileFromDir(fsys, "lib")
+	assert.NoError(t, err)
+
+	_, err = r.addRelation(`relations[info] {
+	# TODO: add keys
+	info := {"name": "policy"}
+}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `package relations
+
+import data.snyk
+
+# Buckets and their ACLs
+relations[info] {
+	info := {"name": "acl"}
+}
+
+relations[info] {
+	# TODO: add keys
+	info := {"name": "policy"}
+}
+`, string(r.pendingContents))
+
+	_, err = r.addRelation("relations[info] {\n\ttrue\n}\n\nrelations[info] {\n\ttrue\n}")
+	assert.ErrorContains(t, err, "expected a single relation rule, got 2")
+}
@@ -33,8 +33,23 @@ var ErrFailedToUnmarshalManifest = errors.New("failed to unmarshal manifest")
 
 // Manifest contains metadata about the custom rules project.
 type Manifest struct {
-	Name string         `json:"name"`
-	Push []ManifestPush `json:"push,omitempty"`
+	Name     string                     `json:"name"`
+	Push     []ManifestPush             `json:"push,omitempty"`
+	Profiles map[string]ManifestProfile `json:"profiles,omitempty"`
+	// SigningKey is the path to an ed25519 private key used to sign bundles.
+	SigningKey string `json:"signing_key,omitempty"`
+	// VerificationKey is the path to the ed25519 public key used to verify
+	// signed bundles.
+	VerificationKey string `json:"verification_key,omitempty"`
+	// Exclude lists glob patterns, relative to the project directory, of
+	// files that are left out of the bundle, the rego providers and the specs.
+	Exclude []string `json:"exclude,omitempty"`
+	// BundleLimits overrides the default limits used by the bundle checks.
+	BundleLimits *ManifestBundleLimits `json:"bundle_limits,omitempty"`
+	// Templates lists directories, relative to the project directory, with
+	// rule and spec templates for init. The project's templates directory is
+	// always used.
+	Templates []string `json:"templates,omitempty"`
 }
 
 // ManifestPush contains metadata about where this rule bundle should be pushed
@@ -42,6 +57,46 @@ type Manifest struct {
 type ManifestPush struct {
 	CustomRulesID  string `json:"custom_rules_id,omitempty"`
 	OrganizationID string `json:"organization_id,omitempty"`
+	// Digest is the digest of the bundle that was last pushed from this
+	// project.
+	Digest string `json:"digest,omitempty"`
+	// ETag identifies the version of the upstream bundle after the last push,
+	// if the API returned one.
+	ETag string `json:"etag,omitempty"`
+}
+
+// ManifestProfile describes a named push target, e.g. dev, staging or prod.
+// Any field that is left empty falls back to the CLI configuration.
+type ManifestProfile struct {
+	OrganizationIDs []string `json:"organization_ids,omitempty"`
+	APIURL          string   `json:"api_url,omitempty"`
+	IacNewEngine    *bool    `json:"iac_new_engine,omitempty"`
+}
+
+// ManifestBundleLimits sets the size limits, in bytes, that the bundle checks
+// enforce. Zero values fall back to the defaults.
+type ManifestBundleLimits struct {
+	MaxFileSize   int64 `json:"max_file_size,omitempty"`
+	MaxBundleSize int64 `json:"max_bundle_size,omitempty"`
+}
+
+// Profile returns the profile with the given name.
+func (m Manifest) Profile(name string) (ManifestProfile, bool) {
+	profile, ok := m.Profiles[name]
+	return profile.copy(), ok
+}
+
+func (p ManifestProfile) copy() ManifestProfile {
+	cpy := p
+	if p.OrganizationIDs != nil {
+		cpy.OrganizationIDs = make([]string, len(p.OrganizationIDs))
+		copy(cpy.OrganizationIDs, p.OrganizationIDs)
+	}
+	if p.IacNewEngine != nil {
+		iacNewEngine := *p.IacNewEngine
+		cpy.IacNewEngine = &iacNewEngine
+	}
+	return cpy
 }
 
 // copy creates a copy of the manifest so we don't accidentally modify the
@@ -52,6 +107,24 @@ func (m Manifest) copy() Manifest {
 		cpy.Push = make([]ManifestPush, len(m.Push))
 		copy(cpy.Push, m.Push)
 	}
+	if m.Profiles != nil {
+		cpy.Profiles = make(map[string]ManifestProfile, len(m.Profiles))
+		for name, profile := range m.Profiles {
+			cpy.Profiles[name] = profile.copy()
+		}
+	}
+	if m.Exclude != nil {
+		cpy.Exclude = make([]string, len(m.Exclude))
+		copy(cpy.Exclude, m.Exclude)
+	}
+	if m.Templates != nil {
+		cpy.Templates = make([]string, len(m.Templates))
+		copy(cpy.Templates, m.Templates)
+	}
+	if m.BundleLimits != nil {
+		limits := *m.BundleLimits
+		cpy.BundleLimits = &limits
+	}
 	return cpy
 }
 
@@ -63,9 +136,9 @@ type manifestFile struct {
 func (m *manifestFile) WriteChanges(fsys afero.Fs) error {
 	// This implementation is simpler if we just always update the manifest
 	// file.
-	b, err := json.MarshalIndent(m.manifest, "", "  ")
+	b, err := m.contents()
 	if err != nil {
-		return fmt.Errorf("%w: %s", ErrFailedToMarshalManifest, err)
+		return err
 	}
 	m.File.UpdateContents(b)
 	if err := m.File.WriteChanges(fsys); err != nil {
@@ -75,6 +148,14 @@ func (m *manifestFile) WriteChanges(fsys afero.Fs) error {
 	return nil
 }
 
+func (m *manifestFile) contents() ([]byte, error) {
+	b, err := json.MarshalIndent(m.manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToMarshalManifest, err)
+	}
+	return b, nil
+}
+
 func (m *manifestFile) UpdateContents(manifest Manifest) {
 	m.manifest = manifest
 }
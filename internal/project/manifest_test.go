@@ -26,9 +26,21 @@ func TestManifestFromDir(t *testing.T) {
 	fsys.Mkdir("empty", 0755)
 	fsys.Mkdir("existing", 0755)
 	fsys.Mkdir("error", 0755)
+	fsys.Mkdir("profiles", 0755)
 	afero.WriteFile(fsys, "existing/manifest.json", []byte(`{"name": "test"}`), 0644)
+	afero.WriteFile(fsys, "profiles/manifest.json", []byte(`{
+		"name": "test",
+		"profiles": {
+			"prod": {
+				"organization_ids": ["org-1", "org-2"],
+				"api_url": "https://api.snyk.io",
+				"iac_new_engine": true
+			}
+		}
+	}`), 0644)
 	afero.WriteFile(fsys, "error/manifest.json", []byte(`[]`), 0644)
 
+	iacNewEngine := true
 	testCases := []struct {
 		name          string
 		root          string
@@ -45,6 +57,23 @@ func TestManifestFromDir(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "from manifest file with profiles",
+			root: "profiles",
+			expected: &manifestFile{
+				File: ExistingFile("profiles/manifest.json"),
+				manifest: Manifest{
+					Name: "test",
+					Profiles: map[string]ManifestProfile{
+						"prod": {
+							OrganizationIDs: []string{"org-1", "org-2"},
+							APIURL:          "https://api.snyk.io",
+							IacNewEngine:    &iacNewEngine,
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "non-existing manifest file",
 			root: "empty",
@@ -114,3 +143,33 @@ func TestWriteChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestCopy(t *testing.T) {
+	iacNewEngine := false
+	original := Manifest{
+		Name: "test",
+		Push: []ManifestPush{{OrganizationID: "org-1"}},
+		Profiles: map[string]ManifestProfile{
+			"dev": {
+				OrganizationIDs: []string{"org-1"},
+				IacNewEngine:    &iacNewEngine,
+			},
+		},
+	}
+	cpy := original.copy()
+	cpy.Push[0].OrganizationID = "modified"
+	cpy.Profiles["dev"].OrganizationIDs[0] = "modified"
+	*cpy.Profiles["dev"].IacNewEngine = true
+	cpy.Profiles["prod"] = ManifestProfile{}
+
+	assert.Equal(t, "org-1", original.Push[0].OrganizationID)
+	assert.Equal(t, []string{"org-1"}, original.Profiles["dev"].OrganizationIDs)
+	assert.False(t, *original.Profiles["dev"].IacNewEngine)
+	assert.NotContains(t, original.Profiles, "prod")
+
+	profile, ok := original.Profile("dev")
+	assert.True(t, ok)
+	assert.Equal(t, original.Profiles["dev"], profile)
+	_, ok = original.Profile("prod")
+	assert.False(t, ok)
+}
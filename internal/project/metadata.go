@@ -0,0 +1,221 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/format"
+	"github.com/spf13/afero"
+)
+
+// ErrUnsupportedMetadata is returned when the metadata of a rule can not be
+// edited because it is not an object literal
+var ErrUnsupportedMetadata = errors.New("rule metadata is not an object literal")
+
+// RuleMetadataSource is the metadata of a rule along with the rego file that
+// declares it.
+type RuleMetadataSource struct {
+	Path     string
+	Metadata RuleMetadata
+	file     *File
+	module   *ast.Module
+	rule     *ast.Rule
+}
+
+// RuleMetadataSources reads the metadata literals of all rules in the project
+// from their rego files, sorted by rule ID. Unlike RuleMetadata, the rules
+// are not evaluated, so that the metadata can be changed with
+// UpdateRuleMetadata.
+func (p *Project) RuleMetadataSources() ([]RuleMetadataSource, error) {
+	var sources []RuleMetadataSource
+	for _, rule := range p.rulesDir.rules {
+		if !rule.Exists() {
+			continue
+		}
+		for name, node := range rule.files {
+			file, ok := node.(*File)
+			if !ok || filepath.Ext(name) != ".rego" || p.Excluded(file.Path()) {
+				continue
+			}
+			source, ok, err := readRuleMetadataSource(p.FS, file)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				sources = append(sources, source)
+			}
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Metadata.ID != sources[j].Metadata.ID {
+			return sources[i].Metadata.ID < sources[j].Metadata.ID
+		}
+		return sources[i].Path < sources[j].Path
+	})
+	return sources, nil
+}
+
+// UpdateRuleMetadata stages replacing the metadata of a rule. Fields that are
+// not part of RuleMetadata are kept, and the rego file is re-formatted.
+func (p *Project) UpdateRuleMetadata(source RuleMetadataSource, metadata RuleMetadata) error {
+	if source.file == nil {
+		return fmt.Errorf("%w: %s was not read with RuleMetadataSources", ErrUnsupportedMetadata, source.Path)
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return err
+	}
+	existing := source.rule.Head.Value.Value.(ast.Object)
+	updated := ast.NewObject()
+	// The formatter lays out object items by their location, so the items
+	// keep the row of the item they replace and new items are added after
+	// the last one.
+	row := source.rule.Head.Value.Location.Row
+	seen := map[string]bool{}
+	var setErr error
+	set := func(key string, keyRow int) {
+		seen[key] = true
+		value, ok := fields[key]
+		if !ok {
+			return
+		}
+		v, err := ast.InterfaceToValue(value)
+		if err != nil {
+			setErr = err
+			return
+		}
+		if keyRow == 0 {
+			row++
+			keyRow = row
+		}
+		updated.Insert(termAt(ast.String(key), keyRow, source.Path), termAt(v, keyRow, source.Path))
+	}
+	known := map[string]bool{}
+	for _, key := range ruleMetadataFields() {
+		known[key] = true
+	}
+	existing.Foreach(func(k, v *ast.Term) {
+		var keyRow int
+		if k.Location != nil {
+			keyRow = k.Location.Row
+			row = max(row, keyRow)
+		}
+		if key, ok := k.Value.(ast.String); ok && known[string(key)] {
+			set(string(key), keyRow)
+		} else {
+			updated.Insert(k, v)
+		}
+	})
+	for _, key := range ruleMetadataFields() {
+		if !seen[key] {
+			set(key, 0)
+		}
+	}
+	if setErr != nil {
+		return setErr
+	}
+	source.rule.Head.Value = ast.NewTerm(updated)
+	formatted, err := format.Ast(source.module)
+	if err != nil {
+		return pathError(source.Path, ErrFailedToParseRegoFile, err)
+	}
+	source.file.UpdateContents(formatted)
+	return nil
+}
+
+// readRuleMetadataSource reads the metadata rule of a rego file. It returns
+// false for files without one, such as rego tests.
+func readRuleMetadataSource(fsys afero.Fs, file *File) (RuleMetadataSource, bool, error) {
+	contents, err := afero.ReadFile(fsys, file.Path())
+	if err != nil {
+		return RuleMetadataSource{}, false, readPathError(file.Path(), err)
+	}
+	module, err := ast.ParseModule(file.Path(), string(contents))
+	if err != nil {
+		return RuleMetadataSource{}, false, pathError(file.Path(), ErrFailedToParseRegoFile, err)
+	}
+	if _, ok := rulePackage(module); !ok {
+		return RuleMetadataSource{}, false, nil
+	}
+	rule, metadata, err := decodeMetadata(module)
+	if err != nil {
+		return RuleMetadataSource{}, false, pathError(file.Path(), ErrUnsupportedMetadata, err)
+	}
+	if rule == nil {
+		return RuleMetadataSource{}, false, nil
+	}
+	return RuleMetadataSource{
+		Path:     file.Path(),
+		Metadata: metadata,
+		file:     file,
+		module:   module,
+		rule:     rule,
+	}, true, nil
+}
+
+// decodeMetadata returns the metadata rule of a module and its decoded value.
+// The rule is nil if the module has no metadata rule.
+func decodeMetadata(module *ast.Module) (*ast.Rule, RuleMetadata, error) {
+	for _, rule := range module.Rules {
+		if rule.Head.Name != "metadata" {
+			continue
+		}
+		var obj ast.Object
+		if rule.Head.Value != nil {
+			obj, _ = rule.Head.Value.Value.(ast.Object)
+		}
+		if obj == nil || !obj.IsGround() {
+			return nil, RuleMetadata{}, fmt.Errorf("metadata must be a constant object")
+		}
+		value, err := ast.JSON(obj)
+		if err != nil {
+			return nil, RuleMetadata{}, err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, RuleMetadata{}, err
+		}
+		var metadata RuleMetadata
+		if err := json.Unmarshal(encoded, &metadata); err != nil {
+			return nil, RuleMetadata{}, err
+		}
+		return rule, metadata, nil
+	}
+	return nil, RuleMetadata{}, nil
+}
+
+// termAt returns a term for the given value that the formatter places on the
+// given row.
+func termAt(value ast.Value, row int, file string) *ast.Term {
+	return &ast.Term{
+		Value: value,
+		Location: &ast.Location{
+			Row:  row,
+			Col:  1,
+			File: file,
+			Text: []byte(value.String()),
+		},
+	}
+}
@@ -0,0 +1,118 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectUpdateRuleMetadata(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"rules/ACME_001/main.rego": `package rules.ACME_001
+
+input_type := "tf"
+
+# Rule metadata
+metadata := {
+	"id": "ACME-001",
+	"severity": "low",
+	"title": "Bucket is public",
+	"description": "",
+	"product": ["iac"],
+	"labels": ["pci"],
+	"custom": "kept",
+}
+
+deny[info] {
+	info := {"resource": input}
+}
+`,
+		"rules/ACME_001/main_test.rego": "package rules.ACME_001\n\ntest_deny {\n\tcount(deny) == 1\n}\n",
+		"rules/ACME_002/main.rego": `package rules.ACME_002
+
+metadata := {"id": "ACME-002", "severity": "high", "product": ["iac"]}
+`,
+		"rules/DYNAMIC/main.rego": `package rules.DYNAMIC
+
+metadata := {"id": id}
+
+id := "DYNAMIC"
+`,
+	}
+	for path, contents := range files {
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(contents), 0644))
+	}
+	prj, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+
+	_, err = prj.RuleMetadataSources()
+	assert.ErrorIs(t, err, ErrUnsupportedMetadata)
+	require.NoError(t, fsys.RemoveAll("rules/DYNAMIC"))
+	prj, err = FromDir(fsys, ".")
+	require.NoError(t, err)
+
+	sources, err := prj.RuleMetadataSources()
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, "rules/ACME_001/main.rego", sources[0].Path)
+	assert.Equal(t, RuleMetadata{
+		ID:       "ACME-001",
+		Severity: "low",
+		Title:    "Bucket is public",
+		Product:  []string{"iac"},
+		Labels:   []string{"pci"},
+	}, sources[0].Metadata)
+	assert.Equal(t, "ACME-002", sources[1].Metadata.ID)
+
+	metadata := sources[0].Metadata
+	metadata.Severity = "medium"
+	metadata.Labels = nil
+	metadata.Category = "storage"
+	require.NoError(t, prj.UpdateRuleMetadata(sources[0], metadata))
+	require.NoError(t, prj.WriteChanges())
+
+	contents, err := afero.ReadFile(fsys, "rules/ACME_001/main.rego")
+	require.NoError(t, err)
+	assert.Equal(t, `package rules.ACME_001
+
+input_type := "tf"
+
+# Rule metadata
+metadata := {
+	"id": "ACME-001",
+	"severity": "medium",
+	"title": "Bucket is public",
+	"description": "",
+	"product": ["iac"],
+	"custom": "kept",
+	"category": "storage",
+}
+
+deny[info] {
+	info := {"resource": input}
+}
+`, string(contents))
+
+	reloaded, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+	sources, err = reloaded.RuleMetadataSources()
+	require.NoError(t, err)
+	assert.Equal(t, metadata, sources[0].Metadata)
+}
@@ -17,6 +17,9 @@ package project
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/open-policy-agent/opa/ast"
@@ -37,6 +40,12 @@ type Project struct {
 	libDir       *libDir
 	specDir      *specDir
 	manifestFile *manifestFile
+	historyFile  *historyFile
+	archivesDir  *archivesDir
+	// pendingFiles are changes to existing files that are not otherwise
+	// tracked, e.g. rego files that refer to a renamed rule. They are written
+	// last, after any directories have been moved.
+	pendingFiles []*File
 }
 
 // WriteChanges persists any changes to this project back to disk. This
@@ -57,6 +66,18 @@ func (p *Project) WriteChanges() error {
 	if err := p.manifestFile.WriteChanges(p.FS); err != nil {
 		return err
 	}
+	if err := p.historyFile.WriteChanges(p.FS); err != nil {
+		return err
+	}
+	if err := p.archivesDir.WriteChanges(p.FS); err != nil {
+		return err
+	}
+	for _, f := range p.pendingFiles {
+		if err := f.WriteChanges(p.FS); err != nil {
+			return err
+		}
+	}
+	p.pendingFiles = nil
 	return nil
 }
 
@@ -70,6 +91,43 @@ func (p *Project) UpdateManifest(m Manifest) {
 	p.manifestFile.UpdateContents(m)
 }
 
+// History returns a copy of the project's push history, oldest first.
+func (p *Project) History() []HistoryEntry {
+	history := make([]HistoryEntry, len(p.historyFile.entries))
+	copy(history, p.historyFile.entries)
+	return history
+}
+
+// RecordPush appends an entry to the project's push history.
+func (p *Project) RecordPush(entry HistoryEntry) {
+	p.historyFile.addEntry(entry)
+}
+
+// CacheArchive stores a built bundle archive in the project's local archive
+// cache so that it can be re-uploaded later. It returns the path of the cached
+// archive.
+func (p *Project) CacheArchive(digest string, archive []byte) string {
+	return p.archivesDir.addFile(archiveName(digest), archive)
+}
+
+// HasCachedArchive returns whether a bundle archive with the given digest is
+// already cached.
+func (p *Project) HasCachedArchive(digest string) (bool, error) {
+	return p.archivesDir.hasFile(p.FS, archiveName(digest))
+}
+
+// CachedArchive returns a previously cached bundle archive for the given
+// digest.
+func (p *Project) CachedArchive(digest string) ([]byte, error) {
+	return p.archivesDir.readFile(p.FS, archiveName(digest))
+}
+
+// CacheArchiveSignature stores the signature for a cached bundle archive next
+// to the archive. It returns the path of the signature.
+func (p *Project) CacheArchiveSignature(digest string, signature []byte) string {
+	return p.archivesDir.addFile(archiveName(digest)+".sig", signature)
+}
+
 // ListRules lists the rule directories in the project.
 func (p *Project) ListRules() []string {
 	return p.rulesDir.ruleDirNames()
@@ -90,6 +148,20 @@ func (p *Project) AddRule(ruleID string, regoFileName string, contents []byte) (
 	return p.rulesDir.addRule(ruleDirName, safeRegoFileName, contents)
 }
 
+// AddRuleFile adds a file, e.g. a rego test, to an existing rule. The file name
+// is transformed in the same way as in AddRule.
+func (p *Project) AddRuleFile(ruleID string, fileName string, contents []byte) (string, error) {
+	ruleDirName, err := SafePackageName(ruleID)
+	if err != nil {
+		return "", err
+	}
+	safeName, err := safeFilename(fileName)
+	if err != nil {
+		return "", err
+	}
+	return p.rulesDir.addRuleFile(ruleDirName, safeName, contents)
+}
+
 // AddRuleSpec adds a rule to the project. The given rule ID will be transformed
 // to a valid package name and the spec name will be transformed to fit similar
 // constraints.
@@ -107,9 +179,16 @@ func (p *Project) AddRuleSpec(ruleID string, name string, contents []byte) (stri
 
 // RuleSpecs returns the rule specs in the project. The returned fixtures can be
 // modified in-place, then the changes can be persisted by calling WriteChanges
-// on the project.
+// on the project. Specs that are excluded, or whose rule is, are left out.
 func (p *Project) RuleSpecs() []*RuleSpec {
-	return p.specDir.fixtures()
+	var specs []*RuleSpec
+	for _, spec := range p.specDir.fixtures() {
+		ruleDir := filepath.Join(p.rulesDir.Path(), spec.RuleDirName)
+		if !p.Excluded(spec.Input.Path()) && !p.Excluded(ruleDir) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
 }
 
 // AddRelation adds the given relation rule to the relations library for this
@@ -169,7 +248,7 @@ func (p *Project) RuleMetadata() (map[string]RuleMetadata, error) {
 		if r.Error != "" {
 			return nil, fmt.Errorf(r.Error)
 		}
-		metadata[r.Metadata.ID] = RuleMetadata{
+		m := RuleMetadata{
 			ID:           r.Metadata.ID,
 			Severity:     r.Metadata.Severity,
 			Title:        r.Metadata.Title,
@@ -179,7 +258,21 @@ func (p *Project) RuleMetadata() (map[string]RuleMetadata, error) {
 			Labels:       r.Metadata.Labels,
 			Platform:     r.Metadata.Platform,
 			ServiceGroup: r.Metadata.ServiceGroup,
+			Remediation:  r.Metadata.Remediation,
+			Controls:     r.Metadata.Controls,
+		}
+		for kind, refs := range r.Metadata.References {
+			if m.References == nil {
+				m.References = map[string][]MetadataReference{}
+			}
+			for _, ref := range refs {
+				m.References[kind] = append(m.References[kind], MetadataReference{
+					URL:   ref.URL,
+					Title: ref.Title,
+				})
+			}
 		}
+		metadata[r.Metadata.ID] = m
 	}
 	return metadata, nil
 }
@@ -233,8 +326,101 @@ func (p *Project) InputTypeForRule(ruleID string) (string, error) {
 	return inputType, nil
 }
 
+// RegoFiles returns the paths of all rego files in the lib and rules
+// directories of the project, i.e. the files that end up in a rule bundle.
+// Rego tests are left out.
+func (p *Project) RegoFiles() ([]string, error) {
+	files, err := p.bundleFiles()
+	if err != nil {
+		return nil, err
+	}
+	var regoFiles []string
+	for _, path := range files {
+		if bundled(path) {
+			regoFiles = append(regoFiles, path)
+		}
+	}
+	return regoFiles, nil
+}
+
+// bundled returns true if a file in the lib or rules directory is included in
+// the rule bundle. Only rego files are, except for rego tests.
+func bundled(path string) bool {
+	return filepath.Ext(path) == ".rego" && !strings.HasSuffix(path, "_test.rego")
+}
+
+// bundleFiles returns the paths of all files in the lib and rules directories
+// of the project that are not excluded.
+func (p *Project) bundleFiles() ([]string, error) {
+	var files []string
+	for _, dir := range []FSNode{p.libDir, p.rulesDir} {
+		if !dir.Exists() {
+			continue
+		}
+		err := afero.Walk(p.FS, dir.Path(), func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return readPathError(path, err)
+			}
+			if p.Excluded(path) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// CopyBundleFiles copies the manifest and the files that are bundled to the
+// root of the given filesystem, leaving out excluded files, rego tests and
+// files that are not rego.
+func (p *Project) CopyBundleFiles(dst afero.Fs) error {
+	files, err := p.RegoFiles()
+	if err != nil {
+		return err
+	}
+	if p.manifestFile.Exists() {
+		files = append(files, p.manifestFile.Path())
+	}
+	for _, path := range files {
+		rel, err := filepath.Rel(p.Path(), path)
+		if err != nil {
+			return err
+		}
+		contents, err := afero.ReadFile(p.FS, path)
+		if err != nil {
+			return readPathError(path, err)
+		}
+		if err := dst.MkdirAll(filepath.Dir(rel), 0755); err != nil {
+			return err
+		}
+		if err := afero.WriteFile(dst, rel, contents, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Providers returns the rego providers for the lib and rules directories of
+// the project. Excluded files are hidden from them.
 func (p *Project) Providers() (providers []data.Provider) {
-	fsys := afero.NewIOFS(p.FS)
+	var fsys fs.FS = afero.NewIOFS(p.FS)
+	if len(p.manifestFile.manifest.Exclude) > 0 {
+		fsys = excludeFS{
+			FS: fsys,
+			excluded: func(name string) bool {
+				return p.Excluded(filepath.FromSlash(name))
+			},
+		}
+	}
 	if p.libDir.Exists() {
 		providers = append(providers, data.FSProvider(fsys, p.libDir.Path()))
 	}
@@ -279,6 +465,14 @@ func FromDir(fsys afero.Fs, root string) (*Project, error) {
 	if err != nil {
 		return nil, err
 	}
+	history, err := historyFromDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	archives, err := archivesFromDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
 	p := &Project{
 		Dir:          dir,
 		FS:           fsys,
@@ -286,6 +480,8 @@ func FromDir(fsys afero.Fs, root string) (*Project, error) {
 		libDir:       lib,
 		specDir:      spec,
 		manifestFile: manifest,
+		historyFile:  history,
+		archivesDir:  archives,
 	}
 	return p, nil
 }
@@ -267,3 +267,29 @@ func TestProjectWriteChanges(t *testing.T) {
 		assert.Equal(t, []string{"aws_s3_bucket.logging"}, relations)
 	})
 }
+
+func TestProjectRegoFiles(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("existing/lib", 0755)
+	fsys.MkdirAll("existing/rules/TEST_001", 0755)
+	fsys.MkdirAll("existing/spec/rules/TEST_001/inputs", 0755)
+	afero.WriteFile(fsys, "existing/lib/relations.rego", testRelationsFile, 0644)
+	afero.WriteFile(fsys, "existing/rules/TEST_001/main.rego", testRule, 0644)
+	afero.WriteFile(fsys, "existing/rules/TEST_001/README.md", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/infra.tf", []byte{}, 0644)
+
+	p, err := FromDir(fsys, "existing")
+	assert.NoError(t, err)
+	files, err := p.RegoFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"existing/lib/relations.rego",
+		"existing/rules/TEST_001/main.rego",
+	}, files)
+
+	p, err = FromDir(fsys, "nonexistent")
+	assert.NoError(t, err)
+	files, err = p.RegoFiles()
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
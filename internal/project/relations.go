@@ -0,0 +1,170 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/snyk/policy-engine/pkg/engine"
+	"github.com/snyk/policy-engine/pkg/models"
+	"github.com/snyk/policy-engine/pkg/policy"
+	"github.com/snyk/policy-engine/pkg/rego"
+)
+
+var ErrRelationNotFound = errors.New("relation not found")
+
+// RelationResource identifies a resource that is joined by a relation.
+type RelationResource struct {
+	ID   string `json:"id"`
+	Type string `json:"_type"`
+}
+
+func (r RelationResource) String() string {
+	return fmt.Sprintf("%s.%s", r.Type, r.ID)
+}
+
+// RelationPair is a pair of resources that a relation joins.
+type RelationPair struct {
+	Left  RelationResource
+	Right RelationResource
+}
+
+// relationInfo is a relation as defined in the relations library. Resources
+// are related either by equal keys or explicitly.
+type relationInfo struct {
+	Name string `json:"name"`
+	Keys *struct {
+		Left  []relationKey `json:"left"`
+		Right []relationKey `json:"right"`
+	} `json:"keys"`
+	Explicit [][2]RelationResource `json:"explicit"`
+}
+
+// relationKey is a resource along with a key. The key can be any value.
+type relationKey [2]interface{}
+
+// RelationPairs evaluates the relation with the given name against the
+// resources of the given state and returns the pairs of resources that it
+// joins.
+func (p *Project) RelationPairs(name string, state models.State) ([]RelationPair, error) {
+	ctx := context.Background()
+	eng, err := p.Engine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []relationInfo
+	err = eng.Query(ctx, &engine.QueryOptions{
+		Query: "data.relations[_][_]",
+		ResourcesQuery: policy.NewResourcesQueryCache(func(ctx context.Context, req policy.ResourcesQuery) (policy.ResourcesResult, error) {
+			var resources []models.ResourceState
+			for _, r := range state.Resources[req.ResourceType] {
+				resources = append(resources, r)
+			}
+			return policy.ResourcesResult{
+				ScopeFound: true,
+				Resources:  resources,
+			}, nil
+		}),
+		ResultProcessor: func(v ast.Value) error {
+			var info relationInfo
+			if err := rego.Bind(v, &info); err != nil {
+				return err
+			}
+			infos = append(infos, info)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return joinRelation(name, infos)
+}
+
+// joinRelation returns the pairs of resources that the relations with the
+// given name join, sorted by the left and then the right resource.
+func joinRelation(name string, infos []relationInfo) ([]RelationPair, error) {
+	var found bool
+	seen := map[RelationPair]bool{}
+	pairs := []RelationPair{}
+	add := func(pair RelationPair) {
+		if !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	for _, info := range infos {
+		if info.Name != name {
+			continue
+		}
+		found = true
+		for _, explicit := range info.Explicit {
+			add(RelationPair{Left: explicit[0], Right: explicit[1]})
+		}
+		if info.Keys == nil {
+			continue
+		}
+		right := map[string][]RelationResource{}
+		for _, k := range info.Keys.Right {
+			resource, key, err := k.decode()
+			if err != nil {
+				return nil, err
+			}
+			right[key] = append(right[key], resource)
+		}
+		for _, k := range info.Keys.Left {
+			resource, key, err := k.decode()
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range right[key] {
+				add(RelationPair{Left: resource, Right: r})
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrRelationNotFound, name)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Left != pairs[j].Left {
+			return pairs[i].Left.String() < pairs[j].Left.String()
+		}
+		return pairs[i].Right.String() < pairs[j].Right.String()
+	})
+	return pairs, nil
+}
+
+// decode returns the resource of the key and the key as JSON, so that equal
+// keys can be looked up.
+func (k relationKey) decode() (RelationResource, string, error) {
+	var resource RelationResource
+	b, err := json.Marshal(k[0])
+	if err != nil {
+		return resource, "", err
+	}
+	if err := json.Unmarshal(b, &resource); err != nil {
+		return resource, "", fmt.Errorf("invalid resource in relation keys: %w", err)
+	}
+	key, err := json.Marshal(k[1])
+	if err != nil {
+		return resource, "", err
+	}
+	return resource, string(key), nil
+}
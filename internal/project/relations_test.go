@@ -0,0 +1,118 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+func TestJoinRelation(t *testing.T) {
+	var infos []relationInfo
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{
+			"name": "bucket_policy",
+			"keys": {
+				"left": [
+					[{"id": "b", "_type": "aws_s3_bucket"}, "b"],
+					[{"id": "a", "_type": "aws_s3_bucket"}, "a"],
+					[{"id": "c", "_type": "aws_s3_bucket"}, ["c", 1]]
+				],
+				"right": [
+					[{"id": "p2", "_type": "aws_s3_bucket_policy"}, "a"],
+					[{"id": "p1", "_type": "aws_s3_bucket_policy"}, "a"],
+					[{"id": "p3", "_type": "aws_s3_bucket_policy"}, ["c", 1]],
+					[{"id": "p4", "_type": "aws_s3_bucket_policy"}, "d"]
+				]
+			}
+		},
+		{
+			"name": "bucket_policy",
+			"explicit": [
+				[{"id": "b", "_type": "aws_s3_bucket"}, {"id": "p4", "_type": "aws_s3_bucket_policy"}],
+				[{"id": "a", "_type": "aws_s3_bucket"}, {"id": "p1", "_type": "aws_s3_bucket_policy"}]
+			]
+		},
+		{
+			"name": "other",
+			"explicit": [
+				[{"id": "x", "_type": "aws_s3_bucket"}, {"id": "y", "_type": "aws_s3_bucket"}]
+			]
+		}
+	]`), &infos))
+
+	pairs, err := joinRelation("bucket_policy", infos)
+	require.NoError(t, err)
+	bucket := func(id string) RelationResource { return RelationResource{ID: id, Type: "aws_s3_bucket"} }
+	policy := func(id string) RelationResource { return RelationResource{ID: id, Type: "aws_s3_bucket_policy"} }
+	assert.Equal(t, []RelationPair{
+		{Left: bucket("a"), Right: policy("p1")},
+		{Left: bucket("a"), Right: policy("p2")},
+		{Left: bucket("b"), Right: policy("p4")},
+		{Left: bucket("c"), Right: policy("p3")},
+	}, pairs)
+
+	pairs, err = joinRelation("unused", []relationInfo{{Name: "unused"}})
+	require.NoError(t, err)
+	assert.Empty(t, pairs)
+
+	_, err = joinRelation("missing", infos)
+	assert.ErrorIs(t, err, ErrRelationNotFound)
+}
+
+func TestRelationPairs(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	afero.WriteFile(fsys, "project/lib/relations.rego", testRelationsFile, 0644)
+	prj, err := FromDir(fsys, "project")
+	require.NoError(t, err)
+
+	// A spec input in which only the data bucket has logging configured.
+	inputPath := filepath.Join(t.TempDir(), "infra.tf")
+	require.NoError(t, os.WriteFile(inputPath, []byte(`
+resource "aws_s3_bucket" "data" {
+  bucket = "data"
+}
+
+resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+
+resource "aws_s3_bucket_logging" "data" {
+  bucket        = "data"
+  target_bucket = "logs"
+}
+`), 0644))
+	input, err := utils.LoadSingleInput(inputPath)
+	require.NoError(t, err)
+
+	pairs, err := prj.RelationPairs("aws_s3_bucket.logging", input.State)
+	require.NoError(t, err)
+	assert.Equal(t, []RelationPair{{
+		Left:  RelationResource{ID: "aws_s3_bucket.data", Type: "aws_s3_bucket"},
+		Right: RelationResource{ID: "aws_s3_bucket_logging.data", Type: "aws_s3_bucket_logging"},
+	}}, pairs)
+	assert.Equal(t, "aws_s3_bucket.aws_s3_bucket.data", pairs[0].Left.String())
+
+	_, err = prj.RelationPairs("aws_s3_bucket.policy", input.State)
+	assert.ErrorIs(t, err, ErrRelationNotFound)
+}
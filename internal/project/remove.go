@@ -0,0 +1,182 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/afero"
+)
+
+// RemoveRule removes a rule along with its specs and their expected output.
+// It returns the paths that will be removed.
+func (p *Project) RemoveRule(ruleID string) ([]string, error) {
+	dir, _, err := p.ruleDirForID(ruleID)
+	if err != nil {
+		return nil, err
+	}
+	path, err := p.rulesDir.removeRule(dir)
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{path}
+	if specPath, ok := p.specDir.removeRuleSpecs(dir); ok {
+		removed = append(removed, specPath)
+	}
+	return removed, nil
+}
+
+// RemoveRuleSpec removes a spec of a rule along with its expected output. The
+// name is the file name of the spec's input, with or without its extension.
+// It returns the paths that will be removed.
+func (p *Project) RemoveRuleSpec(ruleID string, name string) ([]string, error) {
+	dir, _, err := p.ruleDirForID(ruleID)
+	if errors.Is(err, ErrRuleNotFound) {
+		// Specs can outlive their rule, so we fall back to the directory
+		// that AddRuleSpec uses.
+		dir, err = SafePackageName(ruleID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	specs, ok := p.specDir.ruleSpecs[dir]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has no specs", ErrRuleSpecNotFound, ruleID)
+	}
+	spec, err := specs.removeFixture(name)
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{spec.Input.Path()}
+	if spec.Expected.Exists() {
+		removed = append(removed, spec.Expected.Path())
+	}
+	return removed, nil
+}
+
+// UnusedRelations returns the relations defined in the project's relations
+// library that none of the rules use. Only relation names that are passed
+// as string literals to snyk.relates or snyk.back_relates count as uses.
+// Staged removals of rules are taken into account.
+func (p *Project) UnusedRelations() ([]string, error) {
+	defined := map[string]struct{}{}
+	for _, rule := range p.libDir.relations.module.Rules {
+		if rule.Head.Name != "relations" {
+			continue
+		}
+		ast.WalkTerms(rule, func(t *ast.Term) bool {
+			if obj, ok := t.Value.(ast.Object); ok {
+				if name, ok := stringValue(obj.Get(ast.StringTerm("name"))); ok {
+					defined[name] = struct{}{}
+				}
+			}
+			return false
+		})
+		walkCalls(rule, "relation_from_fields", func(args []*ast.Term) {
+			if name, ok := stringValue(args[0]); ok {
+				defined[name] = struct{}{}
+			}
+		})
+	}
+
+	var dirs []string
+	for _, rule := range p.rulesDir.rules {
+		if rule.Exists() {
+			dirs = append(dirs, rule.Path())
+		}
+	}
+	if p.libDir.Exists() {
+		dirs = append(dirs, p.libDir.Path())
+	}
+	for _, dir := range dirs {
+		err := afero.Walk(p.FS, dir, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return readPathError(path, err)
+			}
+			if info.IsDir() || filepath.Ext(path) != ".rego" {
+				return nil
+			}
+			contents, err := afero.ReadFile(p.FS, path)
+			if err != nil {
+				return readPathError(path, err)
+			}
+			module, err := ast.ParseModule(path, string(contents))
+			if err != nil {
+				return pathError(path, ErrFailedToParseRegoFile, err)
+			}
+			for _, name := range []string{"relates", "back_relates"} {
+				walkCalls(module, name, func(args []*ast.Term) {
+					if len(args) > 1 {
+						if relation, ok := stringValue(args[1]); ok {
+							delete(defined, relation)
+						}
+					}
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var unused []string
+	for name := range defined {
+		unused = append(unused, name)
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+// walkCalls calls fn with the arguments of every call to a function whose
+// name ends in the given name, e.g. snyk.relates for "relates".
+func walkCalls(x interface{}, name string, fn func(args []*ast.Term)) {
+	visit := func(operator ast.Ref, args []*ast.Term) {
+		if len(operator) == 0 || len(args) == 0 {
+			return
+		}
+		last := operator[len(operator)-1].Value
+		if last.Compare(ast.String(name)) == 0 || last.Compare(ast.Var(name)) == 0 {
+			fn(args)
+		}
+	}
+	ast.WalkExprs(x, func(expr *ast.Expr) bool {
+		if expr.IsCall() {
+			visit(expr.Operator(), expr.Operands())
+		}
+		return false
+	})
+	ast.WalkTerms(x, func(t *ast.Term) bool {
+		if call, ok := t.Value.(ast.Call); ok && len(call) > 0 {
+			if operator, ok := call[0].Value.(ast.Ref); ok {
+				visit(operator, call[1:])
+			}
+		}
+		return false
+	})
+}
+
+func stringValue(t *ast.Term) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	s, ok := t.Value.(ast.String)
+	return string(s), ok
+}
@@ -0,0 +1,129 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectRemoveRule(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"rules/ACME_001/main.rego": `package rules.ACME_001
+
+import data.snyk
+
+metadata := {"id": "ACME-001"}
+
+deny[info] {
+	bucket := snyk.resources("aws_s3_bucket")[_]
+	logs := snyk.relates(bucket, "bucket_logging")
+	count(logs) == 0
+	info := {"primary_resource": bucket}
+}
+`,
+		"rules/ACME_001/main_test.rego": "package rules.ACME_001\n",
+		"rules/ACME_002/main.rego": `package rules.ACME_002
+
+import data.snyk
+
+metadata := {"id": "ACME-002"}
+
+deny[info] {
+	policy := snyk.resources("aws_s3_bucket_policy")[_]
+	buckets := snyk.back_relates(policy, "bucket_policy")
+	info := {"primary_resource": policy}
+}
+`,
+		"lib/relations.rego": `package relations
+
+import data.snyk
+
+relations[info] {
+	info := snyk.relation_from_fields(
+		"bucket_logging",
+		{"aws_s3_bucket": ["id"]},
+		{"aws_s3_bucket_logging": ["bucket"]},
+	)
+}
+
+relations[info] {
+	info := snyk.relation_from_fields(
+		"bucket_policy",
+		{"aws_s3_bucket": ["id"]},
+		{"aws_s3_bucket_policy": ["bucket"]},
+	)
+}
+`,
+		"spec/rules/ACME_001/inputs/infra.tf":     `resource "aws_s3_bucket" "b" {}`,
+		"spec/rules/ACME_001/expected/infra.json": `[]`,
+		"spec/rules/ACME_002/inputs/infra.tf":     `resource "aws_s3_bucket" "b" {}`,
+		"spec/rules/ACME_002/inputs/other.tf":     `resource "aws_s3_bucket" "c" {}`,
+		"spec/rules/ACME_002/expected/infra.json": `[]`,
+	}
+	for path, contents := range files {
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(contents), 0644))
+	}
+	prj, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+
+	unused, err := prj.UnusedRelations()
+	require.NoError(t, err)
+	assert.Empty(t, unused)
+
+	_, err = prj.RemoveRule("ACME-404")
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+	_, err = prj.RemoveRuleSpec("ACME-002", "missing")
+	assert.ErrorIs(t, err, ErrRuleSpecNotFound)
+
+	removed, err := prj.RemoveRule("ACME-001")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rules/ACME_001", "spec/rules/ACME_001"}, removed)
+
+	removed, err = prj.RemoveRuleSpec("ACME-002", "infra")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"spec/rules/ACME_002/inputs/infra.tf",
+		"spec/rules/ACME_002/expected/infra.json",
+	}, removed)
+	removed, err = prj.RemoveRuleSpec("ACME-002", "other.tf")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spec/rules/ACME_002/inputs/other.tf"}, removed)
+
+	unused, err = prj.UnusedRelations()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bucket_logging"}, unused)
+
+	require.NoError(t, prj.WriteChanges())
+	exists := func(path string) bool {
+		ok, err := afero.Exists(fsys, path)
+		require.NoError(t, err)
+		return ok
+	}
+	assert.False(t, exists("rules/ACME_001"))
+	assert.False(t, exists("spec/rules/ACME_001"))
+	assert.False(t, exists("spec/rules/ACME_002/inputs/infra.tf"))
+	assert.False(t, exists("spec/rules/ACME_002/inputs/other.tf"))
+	assert.False(t, exists("spec/rules/ACME_002/expected/infra.json"))
+	assert.True(t, exists("rules/ACME_002/main.rego"))
+
+	reloaded, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ACME_002"}, reloaded.ListRules())
+}
@@ -0,0 +1,397 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/afero"
+)
+
+// ErrRuleNotFound is returned when no rule in the project has the given ID
+var ErrRuleNotFound = errors.New("rule not found")
+
+// RenameRule changes the ID of a rule. The package and metadata of the rule
+// are updated, the rule and spec directories are moved to match the new ID,
+// the rule ID and paths in the expected output of the specs are updated, and
+// so are references to the rule's package in other rego files. It returns the
+// paths of the files that were changed.
+func (p *Project) RenameRule(oldID string, newID string) ([]string, error) {
+	oldDir, oldPkg, err := p.ruleDirForID(oldID)
+	if err != nil {
+		return nil, err
+	}
+	newDir, err := SafePackageName(newID)
+	if err != nil {
+		return nil, err
+	}
+	if existing, ok := p.rulesDir.rules[newDir]; ok && newDir != oldDir {
+		return nil, fmt.Errorf("%w: %s", ErrRuleDirAlreadyExists, existing.Path())
+	}
+	oldRulePath := filepath.Join(p.rulesDir.path, oldDir)
+	newRulePath := filepath.Join(p.rulesDir.path, newDir)
+	oldSpecPath := filepath.Join(p.specDir.path, "rules", oldDir)
+	newSpecPath := filepath.Join(p.specDir.path, "rules", newDir)
+	moved := func(path string) string {
+		if rel, err := filepath.Rel(oldRulePath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.Join(newRulePath, rel)
+		}
+		return path
+	}
+
+	// All contents are read before anything is moved.
+	regoFiles, err := p.allRegoFiles()
+	if err != nil {
+		return nil, err
+	}
+	var updates []*File
+	for _, path := range regoFiles {
+		contents, err := afero.ReadFile(p.FS, path)
+		if err != nil {
+			return nil, readPathError(path, err)
+		}
+		renamed, err := renameRuleInModule(path, contents, oldPkg, newDir, oldID, newID)
+		if err != nil {
+			return nil, err
+		}
+		if string(renamed) != string(contents) {
+			file := ExistingFile(moved(path))
+			file.UpdateContents(renamed)
+			updates = append(updates, file)
+		}
+	}
+	expected := map[string][]byte{}
+	if specs, ok := p.specDir.ruleSpecs[oldDir]; ok {
+		for name, spec := range specs.fixtures {
+			if spec.Expected == nil || !spec.Expected.Exists() {
+				continue
+			}
+			contents, err := afero.ReadFile(p.FS, spec.Expected.Path())
+			if err != nil {
+				return nil, readPathError(spec.Expected.Path(), err)
+			}
+			renamed, err := renameInExpected(contents, func(key string, value string) string {
+				switch {
+				case key == "rule_id" && value == oldID:
+					return newID
+				case key == "package" && value == "data.rules."+oldPkg:
+					return "data.rules." + newDir
+				case key == "filepath" && strings.HasPrefix(value, filepath.ToSlash(oldSpecPath)+"/"):
+					return filepath.ToSlash(newSpecPath) + strings.TrimPrefix(value, filepath.ToSlash(oldSpecPath))
+				}
+				return value
+			})
+			if err != nil {
+				// The spec fails either way, so an expected output that
+				// isn't valid JSON is moved unchanged.
+				continue
+			}
+			if string(renamed) != string(contents) {
+				expected[name] = renamed
+			}
+		}
+	}
+
+	if newDir != oldDir {
+		if err := p.rulesDir.moveRule(oldDir, newDir); err != nil {
+			return nil, err
+		}
+		p.specDir.moveRuleSpecs(oldDir, newDir)
+	}
+
+	var changed []string
+	for _, file := range updates {
+		p.pendingFiles = append(p.pendingFiles, file)
+		changed = append(changed, file.Path())
+	}
+	if specs, ok := p.specDir.ruleSpecs[newDir]; ok {
+		for name, contents := range expected {
+			spec := specs.fixtures[name]
+			spec.UpdateExpected(contents)
+			changed = append(changed, spec.Expected.Path())
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// renameInExpected rewrites the string values in the expected output of a
+// spec with rename, which is given the key of each value in an object, or an
+// empty key for values in an array. The output is indented like the test
+// command writes it, and the order of keys is kept.
+func renameInExpected(contents []byte, rename func(key string, value string) string) ([]byte, error) {
+	type container struct {
+		object bool
+		// values is the number of values written so far.
+		values int
+		// key is the current key of an object, if hasKey is set. Otherwise
+		// the next token is a key.
+		key    string
+		hasKey bool
+	}
+	var stack []*container
+	compact := &bytes.Buffer{}
+	// beginValue writes the separator before a value and returns its key.
+	beginValue := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		top.values++
+		if top.object {
+			top.hasKey = false
+			return top.key
+		}
+		if top.values > 1 {
+			compact.WriteByte(',')
+		}
+		return ""
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	dec.UseNumber()
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top.object && !top.hasKey {
+				if key, ok := token.(string); ok {
+					if top.values > 0 {
+						compact.WriteByte(',')
+					}
+					b, err := json.Marshal(key)
+					if err != nil {
+						return nil, err
+					}
+					compact.Write(b)
+					compact.WriteByte(':')
+					top.key, top.hasKey = key, true
+					continue
+				}
+			}
+		}
+		switch token := token.(type) {
+		case json.Delim:
+			switch token {
+			case '{', '[':
+				beginValue()
+				stack = append(stack, &container{object: token == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+			}
+			compact.WriteRune(rune(token))
+		case string:
+			b, err := json.Marshal(rename(beginValue(), token))
+			if err != nil {
+				return nil, err
+			}
+			compact.Write(b)
+		case json.Number:
+			beginValue()
+			compact.WriteString(token.String())
+		case bool:
+			beginValue()
+			compact.WriteString(strconv.FormatBool(token))
+		case nil:
+			beginValue()
+			compact.WriteString("null")
+		}
+	}
+
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(contents, []byte("\n")) {
+		indented.WriteByte('\n')
+	}
+	return indented.Bytes(), nil
+}
+
+// ruleDirForID finds the directory and package of the rule with the given ID.
+// The directory that init would have created for the ID is checked first.
+func (p *Project) ruleDirForID(ruleID string) (dir string, pkg string, err error) {
+	dirs := p.rulesDir.ruleDirNames()
+	sort.Strings(dirs)
+	if safe, err := SafePackageName(ruleID); err == nil {
+		dirs = append([]string{safe}, dirs...)
+	}
+	for _, dir := range dirs {
+		rule, ok := p.rulesDir.rules[dir]
+		if !ok || !rule.Exists() {
+			continue
+		}
+		for name, node := range rule.files {
+			if node.IsDir() || filepath.Ext(name) != ".rego" {
+				continue
+			}
+			contents, err := afero.ReadFile(p.FS, node.Path())
+			if err != nil {
+				return "", "", readPathError(node.Path(), err)
+			}
+			module, err := ast.ParseModule(node.Path(), string(contents))
+			if err != nil {
+				continue
+			}
+			pkg, ok := rulePackage(module)
+			if id := metadataID(module); ok && id != nil && id.Equal(ast.StringTerm(ruleID)) {
+				return dir, pkg, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrRuleNotFound, ruleID)
+}
+
+// allRegoFiles returns the rego files in the lib and rules directories,
+// including excluded files.
+func (p *Project) allRegoFiles() ([]string, error) {
+	var files []string
+	for _, dir := range []FSNode{p.libDir, p.rulesDir} {
+		if !dir.Exists() {
+			continue
+		}
+		err := afero.Walk(p.FS, dir.Path(), func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return readPathError(path, err)
+			}
+			if !info.IsDir() && filepath.Ext(path) == ".rego" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// rulePackage returns the last element of a package under data.rules.
+func rulePackage(module *ast.Module) (string, bool) {
+	path := module.Package.Path
+	if len(path) != 3 || !path[1].Equal(ast.StringTerm("rules")) {
+		return "", false
+	}
+	pkg, ok := path[2].Value.(ast.String)
+	return string(pkg), ok
+}
+
+// metadataID returns the term of the ID in the metadata rule, if any.
+func metadataID(module *ast.Module) *ast.Term {
+	for _, rule := range module.Rules {
+		if rule.Head.Name != "metadata" || rule.Head.Value == nil {
+			continue
+		}
+		if obj, ok := rule.Head.Value.Value.(ast.Object); ok {
+			if id := obj.Get(ast.StringTerm("id")); id != nil {
+				return id
+			}
+		}
+	}
+	return nil
+}
+
+type textEdit struct {
+	offset int
+	length int
+	text   string
+}
+
+// renameRuleInModule rewrites references to the data.rules.<oldPkg> package,
+// and the package and metadata ID of the rule itself, in the source of a rego
+// module. Only the affected terms are changed, so the rest of the formatting
+// is preserved.
+func renameRuleInModule(path string, src []byte, oldPkg, newPkg, oldID, newID string) ([]byte, error) {
+	module, err := ast.ParseModule(path, string(src))
+	if err != nil {
+		return nil, pathError(path, ErrFailedToParseRegoFile, err)
+	}
+	edits := map[int]textEdit{}
+	rename := func(term *ast.Term, text string) {
+		if term.Location == nil {
+			return
+		}
+		if strings.HasPrefix(string(term.Location.Text), `"`) {
+			text = strconv.Quote(text)
+		}
+		edits[term.Location.Offset] = textEdit{
+			offset: term.Location.Offset,
+			length: len(term.Location.Text),
+			text:   text,
+		}
+	}
+	renameRef := func(ref ast.Ref) {
+		if len(ref) >= 3 &&
+			ref[0].Equal(ast.DefaultRootDocument) &&
+			ref[1].Equal(ast.StringTerm("rules")) &&
+			ref[2].Equal(ast.StringTerm(oldPkg)) {
+			rename(ref[2], newPkg)
+		}
+	}
+
+	renameRef(module.Package.Path)
+	// An import without an alias is referred to by the last element of its
+	// path, so those references have to follow the new package name.
+	var importedAs bool
+	for _, imp := range module.Imports {
+		if ref, ok := imp.Path.Value.(ast.Ref); ok && len(ref) == 3 && imp.Alias == "" {
+			importedAs = importedAs || ref[2].Equal(ast.StringTerm(oldPkg))
+		}
+	}
+	ast.WalkRefs(module, func(ref ast.Ref) bool {
+		renameRef(ref)
+		if importedAs && ref[0].Equal(ast.VarTerm(oldPkg)) {
+			rename(ref[0], newPkg)
+		}
+		return false
+	})
+	if pkg, ok := rulePackage(module); ok && pkg == oldPkg {
+		if id := metadataID(module); id != nil && id.Equal(ast.StringTerm(oldID)) {
+			edits[id.Location.Offset] = textEdit{
+				offset: id.Location.Offset,
+				length: len(id.Location.Text),
+				text:   strconv.Quote(newID),
+			}
+		}
+	}
+
+	sorted := make([]textEdit, 0, len(edits))
+	for _, e := range edits {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].offset > sorted[j].offset
+	})
+	out := string(src)
+	for _, e := range sorted {
+		out = out[:e.offset] + e.text + out[e.offset+e.length:]
+	}
+	return []byte(out), nil
+}
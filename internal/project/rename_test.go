@@ -0,0 +1,169 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectRenameRule(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"rules/ACME_001/main.rego": `package rules.ACME_001
+
+input_type := "tf"
+
+metadata := {
+	"id": "ACME-001",
+	"severity": "low",
+}
+
+deny[info] {
+	info := {"resource": input}
+}
+`,
+		"rules/ACME_001/main_test.rego": `package rules.ACME_001
+
+test_deny {
+	count(deny) == 1 with input as {}
+}
+`,
+		"rules/OTHER/main.rego": `package rules.OTHER
+
+import data.rules.ACME_001
+
+metadata := {"id": "OTHER"}
+
+deny[info] {
+	ACME_001.deny[info]
+	data.rules["ACME_001"].deny[_]
+}
+`,
+		"lib/relations.rego":                  "package relations\n",
+		"spec/rules/ACME_001/inputs/infra.tf": `resource "aws_s3_bucket" "b" {}`,
+		"spec/rules/ACME_001/expected/infra.json": `[
+  {
+    "rule_id": "ACME-001",
+    "package": "data.rules.ACME_001",
+    "filepath": "spec/rules/ACME_001/inputs/infra.tf",
+    "resource_id": "ACME-001",
+    "message": "See \"ACME-001\" in spec/rules/ACME_001/README.md"
+  }
+]`,
+	}
+	for path, contents := range files {
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(contents), 0644))
+	}
+	prj, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+
+	_, err = prj.RenameRule("ACME-404", "ACME-002")
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+	_, err = prj.RenameRule("ACME-001", "OTHER")
+	assert.ErrorIs(t, err, ErrRuleDirAlreadyExists)
+
+	changed, err := prj.RenameRule("ACME-001", "ACME-002")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"rules/ACME_002/main.rego",
+		"rules/ACME_002/main_test.rego",
+		"rules/OTHER/main.rego",
+		"spec/rules/ACME_002/expected/infra.json",
+	}, changed)
+	require.NoError(t, prj.WriteChanges())
+
+	read := func(path string) string {
+		b, err := afero.ReadFile(fsys, path)
+		require.NoError(t, err)
+		return string(b)
+	}
+	exists := func(path string) bool {
+		ok, err := afero.Exists(fsys, path)
+		require.NoError(t, err)
+		return ok
+	}
+	assert.False(t, exists("rules/ACME_001"))
+	assert.False(t, exists("spec/rules/ACME_001"))
+	assert.Contains(t, read("rules/ACME_002/main.rego"), "package rules.ACME_002\n")
+	assert.Contains(t, read("rules/ACME_002/main.rego"), `"id": "ACME-002",`)
+	assert.Contains(t, read("rules/ACME_002/main.rego"), `"severity": "low",`)
+	assert.Contains(t, read("rules/ACME_002/main_test.rego"), "package rules.ACME_002\n")
+	assert.Contains(t, read("rules/OTHER/main.rego"), "import data.rules.ACME_002\n")
+	assert.Contains(t, read("rules/OTHER/main.rego"), "\tACME_002.deny[info]\n")
+	assert.Contains(t, read("rules/OTHER/main.rego"), `data.rules["ACME_002"].deny[_]`)
+	assert.Contains(t, read("rules/OTHER/main.rego"), `metadata := {"id": "OTHER"}`)
+	assert.Equal(t, `resource "aws_s3_bucket" "b" {}`, read("spec/rules/ACME_002/inputs/infra.tf"))
+	assert.Equal(t, `[
+  {
+    "rule_id": "ACME-002",
+    "package": "data.rules.ACME_002",
+    "filepath": "spec/rules/ACME_002/inputs/infra.tf",
+    "resource_id": "ACME-001",
+    "message": "See \"ACME-001\" in spec/rules/ACME_001/README.md"
+  }
+]`, read("spec/rules/ACME_002/expected/infra.json"))
+
+	reloaded, err := FromDir(fsys, ".")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ACME_002", "OTHER"}, reloaded.ListRules())
+}
+
+func TestRenameInExpected(t *testing.T) {
+	type location struct {
+		Filepath string `json:"filepath"`
+		Line     int    `json:"line"`
+	}
+	type result struct {
+		RuleID   string                 `json:"rule_id"`
+		Passed   bool                   `json:"passed"`
+		Message  string                 `json:"message"`
+		Location []location             `json:"location"`
+		Context  map[string]interface{} `json:"context"`
+		Graph    interface{}            `json:"graph"`
+	}
+	results := func(ruleID string, path string) []result {
+		return []result{{
+			RuleID:   ruleID,
+			Message:  "<rule_id> " + "OLD",
+			Location: []location{{Filepath: path, Line: 3}},
+			Context:  map[string]interface{}{"": "OLD", "b": []interface{}{"OLD", 1.5, nil}},
+		}}
+	}
+	original, err := json.MarshalIndent(results("OLD", "old/main.tf"), "", "  ")
+	require.NoError(t, err)
+	expected, err := json.MarshalIndent(results("NEW", "new/main.tf"), "", "  ")
+	require.NoError(t, err)
+
+	renamed, err := renameInExpected(original, func(key string, value string) string {
+		switch {
+		case key == "rule_id" && value == "OLD":
+			return "NEW"
+		case key == "filepath":
+			return strings.Replace(value, "old/", "new/", 1)
+		}
+		return value
+	})
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(renamed))
+
+	_, err = renameInExpected([]byte("[{"), func(key string, value string) string { return value })
+	assert.Error(t, err)
+}
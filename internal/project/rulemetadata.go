@@ -14,6 +14,12 @@
 
 package project
 
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
 // RuleMetadata contains all of the rule metadata fields that are supported for
 // custom rules.
 type RuleMetadata struct {
@@ -26,4 +32,88 @@ type RuleMetadata struct {
 	Labels       []string `json:"labels,omitempty"`
 	Platform     []string `json:"platform,omitempty"`
 	ServiceGroup string   `json:"service_group,omitempty"`
+	// Remediation maps input types to remediation guidance.
+	Remediation map[string]string `json:"remediation,omitempty"`
+	// References maps kinds of references, e.g. "general", to links.
+	References map[string][]MetadataReference `json:"references,omitempty"`
+	// Controls maps compliance frameworks to their versions and to the
+	// controls of that version that the rule checks, e.g.
+	// {"CIS-AWS": {"v1.4.0": ["2.1.5"]}}.
+	Controls map[string]map[string][]string `json:"controls,omitempty"`
+}
+
+// MetadataReference is a link to documentation about a rule.
+type MetadataReference struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// GeneralReferences is the kind of references that links without a more
+// specific kind are added as.
+const GeneralReferences = "general"
+
+// ParseControl parses a compliance control of the form
+// <framework>:<version>:<control>, e.g. CIS-AWS:v1.4.0:2.1.5.
+func ParseControl(control string) (framework string, version string, id string, err error) {
+	parts := strings.Split(control, ":")
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid control '%s', must be of the form framework:version:control", control)
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+		if parts[i] == "" {
+			return "", "", "", fmt.Errorf("invalid control '%s', must be of the form framework:version:control", control)
+		}
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// AddControl adds a compliance control of the form
+// <framework>:<version>:<control> to the metadata.
+func (m *RuleMetadata) AddControl(control string) error {
+	framework, version, id, err := ParseControl(control)
+	if err != nil {
+		return err
+	}
+	if m.Controls == nil {
+		m.Controls = map[string]map[string][]string{}
+	}
+	if m.Controls[framework] == nil {
+		m.Controls[framework] = map[string][]string{}
+	}
+	for _, existing := range m.Controls[framework][version] {
+		if existing == id {
+			return nil
+		}
+	}
+	m.Controls[framework][version] = append(m.Controls[framework][version], id)
+	return nil
+}
+
+// MissingAuditFields returns the JSON names of the remediation, references
+// and controls fields that are empty.
+func (m RuleMetadata) MissingAuditFields() []string {
+	var missing []string
+	if len(m.Remediation) == 0 {
+		missing = append(missing, "remediation")
+	}
+	if len(m.References) == 0 {
+		missing = append(missing, "references")
+	}
+	if len(m.Controls) == 0 {
+		missing = append(missing, "controls")
+	}
+	return missing
+}
+
+// ruleMetadataFields returns the JSON field names of RuleMetadata in the order
+// they are declared.
+func ruleMetadataFields() []string {
+	t := reflect.TypeOf(RuleMetadata{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	return fields
 }
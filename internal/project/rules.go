@@ -25,15 +25,31 @@ import (
 // ErrRuleDirAlreadyExists is returned when a rule already exists
 var ErrRuleDirAlreadyExists = errors.New("rule directory already exists")
 
+// ErrRuleDirNotFound is returned when a file is added to a rule that does not
+// exist
+var ErrRuleDirNotFound = errors.New("rule directory not found")
+
+// ErrRuleFileAlreadyExists is returned when a file already exists in a rule
+// directory
+var ErrRuleFileAlreadyExists = errors.New("rule file already exists")
+
 type rulesDir struct {
 	*Dir
 	rules map[string]*ruleDir
+	// removed contains the rule directories that will be deleted.
+	removed []*ruleDir
 }
 
 func (r *rulesDir) WriteChanges(fsys afero.Fs) error {
 	if err := r.Dir.WriteChanges(fsys); err != nil {
 		return err
 	}
+	for _, rule := range r.removed {
+		if err := rule.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
+	r.removed = nil
 	for _, rule := range r.rules {
 		if err := rule.WriteChanges(fsys); err != nil {
 			return err
@@ -52,6 +68,46 @@ func (r *rulesDir) addRule(ruleDirName string, regoFileName string, contents []b
 	return r.rules[ruleDirName].files[regoFileName].Path(), nil
 }
 
+func (r *rulesDir) addRuleFile(ruleDirName string, fileName string, contents []byte) (string, error) {
+	rule, exists := r.rules[ruleDirName]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrRuleDirNotFound, filepath.Join(r.path, ruleDirName))
+	}
+	return rule.addFile(fileName, contents)
+}
+
+// moveRule stages moving an existing rule directory to a new name.
+func (r *rulesDir) moveRule(from string, to string) error {
+	rule, ok := r.rules[from]
+	if !ok || !rule.Exists() {
+		return fmt.Errorf("%w: %s", ErrRuleDirNotFound, filepath.Join(r.path, from))
+	}
+	path := filepath.Join(r.path, to)
+	files := map[string]FSNode{}
+	for name, node := range rule.files {
+		files[name] = movedNode(node, filepath.Join(path, name))
+	}
+	delete(r.rules, from)
+	r.rules[to] = &ruleDir{
+		Dir:   MovedDir(rule.Path(), path),
+		files: files,
+	}
+	return nil
+}
+
+// removeRule stages the removal of a rule directory. It returns the path of
+// the directory.
+func (r *rulesDir) removeRule(name string) (string, error) {
+	rule, ok := r.rules[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrRuleDirNotFound, filepath.Join(r.path, name))
+	}
+	rule.Remove()
+	delete(r.rules, name)
+	r.removed = append(r.removed, rule)
+	return rule.Path(), nil
+}
+
 func (r *rulesDir) ruleDirNames() []string {
 	var names []string
 	for n := range r.rules {
@@ -105,6 +161,9 @@ func (r *ruleDir) WriteChanges(fsys afero.Fs) error {
 	if err := r.Dir.WriteChanges(fsys); err != nil {
 		return err
 	}
+	if r.removed {
+		return nil
+	}
 	for _, f := range r.files {
 		if err := f.WriteChanges(fsys); err != nil {
 			return err
@@ -113,6 +172,16 @@ func (r *ruleDir) WriteChanges(fsys afero.Fs) error {
 	return nil
 }
 
+func (r *ruleDir) addFile(name string, contents []byte) (string, error) {
+	if existing, exists := r.files[name]; exists {
+		return "", fmt.Errorf("%w: %s", ErrRuleFileAlreadyExists, existing.Path())
+	}
+	file := NewFile(filepath.Join(r.path, name))
+	file.UpdateContents(contents)
+	r.files[name] = file
+	return file.Path(), nil
+}
+
 func ruleFromDir(fsys afero.Fs, parent string, name string) (*ruleDir, error) {
 	path := filepath.Join(parent, name)
 	entries, err := afero.ReadDir(fsys, path)
@@ -19,6 +19,7 @@ import (
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRulesFromDir(t *testing.T) {
@@ -116,3 +117,33 @@ func TestRulesDirCreate(t *testing.T) {
 		})
 	}
 }
+
+func TestRulesDirAddRuleFile(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("existing/rules/TEST_001", 0755)
+	afero.WriteFile(fsys, "existing/rules/TEST_001/main.rego", []byte{}, 0644)
+	r, err := rulesFromDir(fsys, "existing")
+	require.NoError(t, err)
+	_, err = r.addRule("TEST_002", "main.rego", []byte("package rules.TEST_002\n"))
+	require.NoError(t, err)
+
+	path, err := r.addRuleFile("TEST_001", "main_test.rego", []byte("package rules.TEST_001\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "existing/rules/TEST_001/main_test.rego", path)
+	path, err = r.addRuleFile("TEST_002", "main_test.rego", []byte("package rules.TEST_002\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "existing/rules/TEST_002/main_test.rego", path)
+
+	_, err = r.addRuleFile("TEST_001", "main.rego", nil)
+	assert.ErrorIs(t, err, ErrRuleFileAlreadyExists)
+	_, err = r.addRuleFile("TEST_003", "main_test.rego", nil)
+	assert.ErrorIs(t, err, ErrRuleDirNotFound)
+
+	require.NoError(t, r.WriteChanges(fsys))
+	b, err := afero.ReadFile(fsys, "existing/rules/TEST_001/main_test.rego")
+	require.NoError(t, err)
+	assert.Equal(t, "package rules.TEST_001\n", string(b))
+	output, err := rulesFromDir(fsys, "existing")
+	require.NoError(t, err)
+	assert.Contains(t, output.rules["TEST_002"].files, "main_test.rego")
+}
@@ -26,6 +26,9 @@ import (
 
 var ErrRuleSpecAlreadyExists = errors.New("rule spec already exists")
 
+// ErrRuleSpecNotFound is returned when a rule has no spec with the given name
+var ErrRuleSpecNotFound = errors.New("rule spec not found")
+
 // RuleSpec represents an input file or directory and an expected output
 // file.
 type RuleSpec struct {
@@ -35,6 +38,20 @@ type RuleSpec struct {
 	Expected    *File
 }
 
+// Name returns the file name of the spec's input.
+func (f *RuleSpec) Name() string {
+	return f.name
+}
+
+// Remove stages the removal of the spec's input and expected output.
+func (f *RuleSpec) Remove() {
+	f.Input.Remove()
+	if f.Expected == nil {
+		f.Expected = NewFile(f.ExpectedPath())
+	}
+	f.Expected.Remove()
+}
+
 // WriteChanges persists any changes to this fixture to disk.
 func (f *RuleSpec) WriteChanges(fsys afero.Fs) error {
 	if err := f.Input.WriteChanges(fsys); err != nil {
@@ -85,12 +102,20 @@ func ruleSpecFromFileInfo(fsys afero.Fs, parent string, info fs.FileInfo, ruleDi
 type specDir struct {
 	*Dir
 	ruleSpecs map[string]*ruleSpecsDir
+	// removed contains the spec directories of rules that will be deleted.
+	removed []*ruleSpecsDir
 }
 
 func (t *specDir) WriteChanges(fsys afero.Fs) error {
 	if err := t.Dir.WriteChanges(fsys); err != nil {
 		return err
 	}
+	for _, rt := range t.removed {
+		if err := rt.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
+	t.removed = nil
 	for _, rt := range t.ruleSpecs {
 		if err := rt.WriteChanges(fsys); err != nil {
 			return err
@@ -125,6 +150,46 @@ func (t *specDir) addRuleSpec(ruleDirName string, name string, contents []byte)
 	return rt.addFixture(name, contents)
 }
 
+// removeRuleSpecs stages the removal of all specs of a rule, if it has any.
+// It returns the path of the rule's spec directory.
+func (t *specDir) removeRuleSpecs(ruleDirName string) (string, bool) {
+	rt, ok := t.ruleSpecs[ruleDirName]
+	if !ok {
+		return "", false
+	}
+	rt.Remove()
+	delete(t.ruleSpecs, ruleDirName)
+	t.removed = append(t.removed, rt)
+	return rt.Path(), true
+}
+
+// moveRuleSpecs stages moving the specs of a rule to a new rule directory
+// name, if the rule has any.
+func (t *specDir) moveRuleSpecs(from string, to string) {
+	rt, ok := t.ruleSpecs[from]
+	if !ok || !rt.Exists() {
+		return
+	}
+	path := filepath.Join(t.Path(), "rules", to)
+	fixtures := map[string]*RuleSpec{}
+	for name, f := range rt.fixtures {
+		fixture := &RuleSpec{
+			name:        f.name,
+			RuleDirName: to,
+			Input:       movedNode(f.Input, filepath.Join(path, "inputs", filepath.Base(f.Input.Path()))),
+		}
+		if f.Expected != nil {
+			fixture.Expected = movedNode(f.Expected, fixture.ExpectedPath()).(*File)
+		}
+		fixtures[name] = fixture
+	}
+	delete(t.ruleSpecs, from)
+	t.ruleSpecs[to] = &ruleSpecsDir{
+		Dir:      MovedDir(rt.Path(), path),
+		fixtures: fixtures,
+	}
+}
+
 func specFromDir(fsys afero.Fs, root string) (*specDir, error) {
 	specPath := filepath.Join(root, "spec")
 	dir, err := DirFromPath(fsys, specPath)
@@ -176,12 +241,23 @@ func specFromDir(fsys afero.Fs, root string) (*specDir, error) {
 type ruleSpecsDir struct {
 	*Dir
 	fixtures map[string]*RuleSpec
+	// removed contains the specs that will be deleted.
+	removed []*RuleSpec
 }
 
 func (t *ruleSpecsDir) WriteChanges(fsys afero.Fs) error {
 	if err := t.Dir.WriteChanges(fsys); err != nil {
 		return err
 	}
+	if t.Dir.removed {
+		return nil
+	}
+	for _, f := range t.removed {
+		if err := f.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
+	t.removed = nil
 
 	for _, f := range t.fixtures {
 		if err := f.WriteChanges(fsys); err != nil {
@@ -205,6 +281,29 @@ func (t *ruleSpecsDir) addFixture(name string, contents []byte) (string, error)
 	return input.Path(), nil
 }
 
+// removeFixture stages the removal of a spec. The name can be given with or
+// without the extension of the input.
+func (t *ruleSpecsDir) removeFixture(name string) (*RuleSpec, error) {
+	f, ok := t.fixtures[name]
+	if !ok {
+		for n, fixture := range t.fixtures {
+			if strings.TrimSuffix(n, filepath.Ext(n)) == name {
+				if f != nil {
+					return nil, fmt.Errorf("spec name %s is ambiguous, include the extension", name)
+				}
+				f = fixture
+			}
+		}
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRuleSpecNotFound, name)
+	}
+	f.Remove()
+	delete(t.fixtures, f.name)
+	t.removed = append(t.removed, f)
+	return f, nil
+}
+
 func ruleSpecsFromDir(fsys afero.Fs, parent string, name string) (*ruleSpecsDir, error) {
 	path := filepath.Join(parent, name)
 	entries, err := afero.ReadDir(fsys, path)
@@ -176,3 +176,36 @@ func TestSpecDirWriteChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestSpecDirMoveRuleSpecs(t *testing.T) {
+	td := &specDir{
+		Dir: ExistingDir("prj/spec"),
+		ruleSpecs: map[string]*ruleSpecsDir{
+			"TEST_001": {
+				Dir: ExistingDir("prj/spec/rules/TEST_001"),
+				fixtures: map[string]*RuleSpec{
+					"infra.tf": {
+						name:        "infra.tf",
+						RuleDirName: "TEST_001",
+						Input:       ExistingFile("prj/spec/rules/TEST_001/inputs/infra.tf"),
+						Expected:    ExistingFile("prj/spec/rules/TEST_001/expected/infra.json"),
+					},
+					"no_expected.tf": {
+						name:        "no_expected.tf",
+						RuleDirName: "TEST_001",
+						Input:       ExistingFile("prj/spec/rules/TEST_001/inputs/no_expected.tf"),
+						Expected:    NewFile("prj/spec/rules/TEST_001/expected/no_expected.json"),
+					},
+				},
+			},
+		},
+	}
+	td.moveRuleSpecs("TEST_001", "TEST_002")
+
+	assert.NotContains(t, td.ruleSpecs, "TEST_001")
+	fixtures := td.ruleSpecs["TEST_002"].fixtures
+	assert.Equal(t, ExistingFile("prj/spec/rules/TEST_002/inputs/infra.tf"), fixtures["infra.tf"].Input)
+	assert.Equal(t, ExistingFile("prj/spec/rules/TEST_002/expected/infra.json"), fixtures["infra.tf"].Expected)
+	assert.Equal(t, ExistingFile("prj/spec/rules/TEST_002/inputs/no_expected.tf"), fixtures["no_expected.tf"].Input)
+	assert.Equal(t, NewFile("prj/spec/rules/TEST_002/expected/no_expected.json"), fixtures["no_expected.tf"].Expected)
+}
@@ -0,0 +1,181 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/snyk/policy-engine/pkg/bundle"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/signing"
+)
+
+const (
+	flagOutput     = "output"
+	flagSigningKey = "signing-key"
+)
+
+func registerBundleWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.bundle")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-bundle", pflag.ExitOnError)
+
+	flagset.String(flagOutput, "bundle.tar.gz", "Path to write the rule bundle archive to")
+	flagset.String(flagSigningKey, "", "Path to an ed25519 private key (PEM) to sign the bundle with")
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, bundleWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func bundleWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	logger := ictx.GetLogger()
+	config := ictx.GetConfiguration()
+	output := config.GetString(flagOutput)
+
+	fsys := afero.NewOsFs()
+	prj, err := project.FromDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	archive, prov, err := buildArchive(prj, logger)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Bundle provenance: %s\n", prov)
+
+	digest, err := bundleDigest(archive)
+	if err != nil {
+		return nil, err
+	}
+	if err := afero.WriteFile(fsys, output, archive, 0644); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote bundle %s to %s.\n", digest, output)
+
+	if keyPath := signingKeyPath(config, prj); keyPath != "" {
+		sig, err := signArchive(fsys, keyPath, archive)
+		if err != nil {
+			return nil, err
+		}
+		b, err := sig.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		sigPath := signing.SignaturePath(output)
+		if err := afero.WriteFile(fsys, sigPath, b, 0644); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Signed bundle with key %s, signature written to %s.\n", sig.PublicKeyFingerprint, sigPath)
+	}
+	return []workflow.Data{}, nil
+}
+
+// buildArchive checks, builds and validates the rule bundle for the given
+// project and returns it as a tar.gz archive that includes a provenance record.
+func buildArchive(prj *project.Project, logger *log.Logger) ([]byte, *provenance, error) {
+	if err := checkBundleContents(prj); err != nil {
+		return nil, nil, err
+	}
+	// The bundle reader has no notion of excluded files or rego tests, so we
+	// build the bundle from a copy of the files that should be included.
+	staged, err := os.MkdirTemp("", "snyk-iac-rules-bundle-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(staged)
+	if err := prj.CopyBundleFiles(afero.NewBasePathFs(afero.NewOsFs(), staged)); err != nil {
+		return nil, nil, err
+	}
+	bundled, err := bundle.BuildBundle(bundle.NewDirReader(staged))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := bundled.Validate(); err != nil {
+		return nil, nil, err
+	}
+	logger.Println("validated bundle")
+
+	targz := &bytes.Buffer{}
+	if err := bundle.NewTarGzWriter(targz).Write(bundled); err != nil {
+		return nil, nil, err
+	}
+	prov, err := newProvenance(prj)
+	if err != nil {
+		return nil, nil, err
+	}
+	archive, err := addProvenance(targz.Bytes(), prov)
+	if err != nil {
+		return nil, nil, err
+	}
+	return archive, prov, nil
+}
+
+// checkBundleContents reports problems with the files that would be bundled
+// and fails if any of them is not just a warning.
+func checkBundleContents(prj *project.Project) error {
+	problems, err := prj.CheckBundleContents()
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+		} else {
+			failed++
+			fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("bundle checks failed with %d errors", failed)
+	}
+	return nil
+}
+
+// signingKeyPath returns the path of the signing key given with --signing-key
+// or, failing that, the one from the manifest. Paths in the manifest are
+// relative to the project directory.
+func signingKeyPath(config configuration.Configuration, prj *project.Project) string {
+	if path := config.GetString(flagSigningKey); path != "" {
+		return path
+	}
+	if path := prj.Manifest().SigningKey; path != "" {
+		return filepath.Join(prj.Path(), path)
+	}
+	return ""
+}
+
+func signArchive(fsys afero.Fs, keyPath string, archive []byte) (signing.Signature, error) {
+	privateKey, err := signing.LoadPrivateKey(fsys, keyPath)
+	if err != nil {
+		return signing.Signature{}, err
+	}
+	return signing.Sign(privateKey, archive), nil
+}
@@ -0,0 +1,237 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+// provenanceFileName is the name of the provenance record inside the bundle
+// archive.
+const provenanceFileName = "provenance.json"
+
+// archiveModTime is the modification time of the files that are appended to
+// an archive. It is fixed so that appending the same file twice yields the
+// same archive.
+var archiveModTime = time.Unix(0, 0).UTC()
+
+// provenance records where a rule bundle was built from, so that the rules
+// enforced in an organization can be traced back to their source.
+type provenance struct {
+	Project          string            `json:"project"`
+	GitCommit        string            `json:"git_commit,omitempty"`
+	GitDirty         bool              `json:"git_dirty"`
+	ExtensionVersion string            `json:"extension_version"`
+	BuildTimestamp   time.Time         `json:"build_timestamp"`
+	Files            map[string]string `json:"files"`
+}
+
+func newProvenance(prj *project.Project) (*provenance, error) {
+	p := &provenance{
+		Project:          prj.Manifest().Name,
+		ExtensionVersion: utils.ExtensionVersion(),
+		BuildTimestamp:   time.Now().UTC(),
+		Files:            map[string]string{},
+	}
+	if commit, dirty, err := utils.GitCommit(prj.Path()); err == nil {
+		p.GitCommit = commit
+		p.GitDirty = dirty
+	}
+	regoFiles, err := prj.RegoFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range regoFiles {
+		contents, err := afero.ReadFile(prj.FS, path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(prj.Path(), path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(contents)
+		p.Files[filepath.ToSlash(rel)] = hex.EncodeToString(sum[:])
+	}
+	return p, nil
+}
+
+func (p *provenance) String() string {
+	commit := p.GitCommit
+	if commit == "" {
+		commit = "unknown"
+	} else if p.GitDirty {
+		commit += " (dirty)"
+	}
+	return fmt.Sprintf(
+		"project %q, git commit %s, extension version %s, built at %s, %d rego files",
+		p.Project,
+		commit,
+		p.ExtensionVersion,
+		p.BuildTimestamp.Format(time.RFC3339),
+		len(p.Files),
+	)
+}
+
+// appendToArchive returns a copy of the given tar.gz archive with an additional
+// file.
+func appendToArchive(targz []byte, name string, contents []byte) ([]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(targz))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, err
+		}
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(contents)),
+		ModTime: archiveModTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(contents); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readFromArchive returns the contents of a file in the given tar.gz archive,
+// or nil if the archive does not contain it.
+func readFromArchive(targz []byte, name string) ([]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(targz))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == name {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// readArchiveFiles returns the contents of the regular files in the given
+// tar.gz archive by their names.
+func readArchiveFiles(targz []byte) (map[string][]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(targz))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = contents
+	}
+}
+
+// bundleDigest returns the content digest of the given bundle archive, which
+// identifies the bundle in the push history and the local archive cache. The
+// provenance record is left out, since its build timestamp differs between
+// builds of the same sources.
+func bundleDigest(targz []byte) (string, error) {
+	files, err := readArchiveFiles(targz)
+	if err != nil {
+		return "", err
+	}
+	delete(files, provenanceFileName)
+	return project.ContentDigest(files), nil
+}
+
+// readProvenance returns the provenance record embedded in the archive, or nil
+// if there is none.
+func readProvenance(targz []byte) (*provenance, error) {
+	b, err := readFromArchive(targz, provenanceFileName)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &provenance{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// addProvenance embeds the given provenance record in the archive.
+func addProvenance(targz []byte, p *provenance) ([]byte, error) {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return appendToArchive(targz, provenanceFileName, b)
+}
@@ -0,0 +1,151 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+func readArchive(t *testing.T, targz []byte) map[string][]byte {
+	gzr, err := gzip.NewReader(bytes.NewReader(targz))
+	require.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = contents
+	}
+	return files
+}
+
+func writeArchive(t *testing.T, files map[string][]byte) []byte {
+	return writeArchiveAt(t, files, time.Time{})
+}
+
+func writeArchiveAt(t *testing.T, files map[string][]byte, modTime time.Time) []byte {
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(contents)),
+			ModTime: modTime,
+		}))
+		_, err := tw.Write(contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func TestAppendToArchive(t *testing.T) {
+	original := writeArchive(t, map[string][]byte{
+		"manifest.json":            []byte(`{}`),
+		"rules/TEST_001/main.rego": []byte("package rules.TEST_001"),
+	})
+	updated, err := appendToArchive(original, "extra.txt", []byte("extra"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"manifest.json":            []byte(`{}`),
+		"rules/TEST_001/main.rego": []byte("package rules.TEST_001"),
+		"extra.txt":                []byte("extra"),
+	}, readArchive(t, updated))
+
+	again, err := appendToArchive(original, "extra.txt", []byte("extra"))
+	require.NoError(t, err)
+	assert.Equal(t, updated, again)
+}
+
+func TestNewProvenance(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("prj/rules/TEST_001", 0755)
+	afero.WriteFile(fsys, "prj/manifest.json", []byte(`{"name":"Test"}`), 0644)
+	afero.WriteFile(fsys, "prj/rules/TEST_001/main.rego", []byte("package rules.TEST_001\n"), 0644)
+	prj, err := project.FromDir(fsys, "prj")
+	require.NoError(t, err)
+
+	prov, err := newProvenance(prj)
+	require.NoError(t, err)
+	assert.Equal(t, "Test", prov.Project)
+	assert.Equal(t, map[string]string{
+		"rules/TEST_001/main.rego": "67bcdbf8034a59130acbe55d9d99556c26c0acd5f17a5d2ae34a1f388ffa9a60",
+	}, prov.Files)
+
+	archive, err := addProvenance(writeArchive(t, map[string][]byte{}), prov)
+	require.NoError(t, err)
+	var embedded provenance
+	require.NoError(t, json.Unmarshal(readArchive(t, archive)[provenanceFileName], &embedded))
+	assert.Equal(t, prov.Files, embedded.Files)
+	assert.Equal(t, prov.Project, embedded.Project)
+
+	read, err := readProvenance(archive)
+	require.NoError(t, err)
+	assert.Equal(t, &embedded, read)
+	read, err = readProvenance(writeArchive(t, map[string][]byte{}))
+	require.NoError(t, err)
+	assert.Nil(t, read)
+}
+
+func TestBundleDigest(t *testing.T) {
+	files := map[string][]byte{
+		"rules/TEST_001/main.rego": []byte("package rules.TEST_001"),
+		"lib/relations.rego":       []byte("package relations"),
+	}
+	first := writeArchiveAt(t, files, time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC))
+	second := writeArchiveAt(t, files, time.Date(2023, 6, 2, 12, 0, 0, 0, time.UTC))
+	require.NotEqual(t, first, second)
+
+	digest, err := bundleDigest(first)
+	require.NoError(t, err)
+	assert.Equal(t, project.ContentDigest(files), digest)
+	other, err := bundleDigest(second)
+	require.NoError(t, err)
+	assert.Equal(t, digest, other)
+
+	// The provenance record differs between builds and is left out.
+	for _, built := range []time.Time{time.Now(), time.Now().Add(time.Hour)} {
+		withProvenance, err := addProvenance(first, &provenance{Project: "Test", BuildTimestamp: built})
+		require.NoError(t, err)
+		other, err = bundleDigest(withProvenance)
+		require.NoError(t, err)
+		assert.Equal(t, digest, other)
+	}
+
+	files["rules/TEST_001/main.rego"] = []byte("package rules.TEST_002")
+	other, err = bundleDigest(writeArchive(t, files))
+	require.NoError(t, err)
+	assert.NotEqual(t, digest, other)
+}
@@ -15,122 +15,559 @@
 package push
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"log"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/snyk/go-application-framework/pkg/configuration"
 	"github.com/snyk/go-application-framework/pkg/workflow"
-	"github.com/snyk/policy-engine/pkg/bundle"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
 
 	"github.com/snyk/cli-extension-iac-rules/internal/constants"
 	"github.com/snyk/cli-extension-iac-rules/internal/project"
 	"github.com/snyk/cli-extension-iac-rules/internal/service"
+	"github.com/snyk/cli-extension-iac-rules/internal/signing"
+	"github.com/snyk/cli-extension-iac-rules/internal/test"
 )
 
-var ()
-
 const (
-	flagDelete = "delete"
+	flagDelete    = "delete"
+	flagOrgs      = "orgs"
+	flagAllOrgs   = "all-orgs"
+	flagProfile   = "profile"
+	flagTo        = "to"
+	flagSkipTests = "skip-tests"
+	flagRecreate  = "recreate"
+	flagForce     = "force"
 )
 
+// pushTimeout bounds how long a push, including its retries, may take across
+// all target organizations.
+const pushTimeout = 10 * time.Minute
+
 func RegisterWorkflows(e workflow.Engine) error {
 	workflowID := workflow.NewWorkflowIdentifier("iac.rules.push")
 	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-push", pflag.ExitOnError)
 
 	flagset.Bool(flagDelete, false, "Delete upstream rule bundle")
+	flagset.String(flagSigningKey, "", "Path to an ed25519 private key (PEM) to sign the bundle with")
+	flagset.Bool(flagSkipTests, false, "Push without running the specs and rego tests first")
+	flagset.Bool(flagRecreate, false, "Create a new upstream rule bundle instead of updating the existing one")
+	flagset.Bool(flagForce, false, "Overwrite the upstream rule bundle even if it was changed by someone else")
+	addTargetFlags(flagset)
 
 	c := workflow.ConfigurationOptionsFromFlagset(flagset)
 
 	if _, err := e.Register(workflowID, c, pushWorkflow); err != nil {
 		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
 	}
-	return nil
+	if err := registerRollbackWorkflow(e); err != nil {
+		return err
+	}
+	if err := registerBundleWorkflow(e); err != nil {
+		return err
+	}
+	return registerVerifyWorkflow(e)
+}
+
+// addTargetFlags adds the flags that select which organizations a workflow
+// uploads to.
+func addTargetFlags(flagset *pflag.FlagSet) {
+	flagset.StringSlice(flagOrgs, []string{}, "Push to the given organizations instead of the current one (can be repeated)")
+	flagset.Bool(flagAllOrgs, false, "Push to every organization listed in the project manifest")
+	flagset.String(flagProfile, "", "Push using the named profile from the project manifest")
 }
 
 func pushWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
-	ctx := context.Background()
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
+	return pushProject(ictx, prj)
+}
+
+// pushProject pushes the bundle of the given project to, or deletes it from,
+// every target organization.
+func pushProject(ictx workflow.InvocationContext, prj *project.Project) ([]workflow.Data, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
 	logger := ictx.GetLogger()
 	config := ictx.GetConfiguration()
-	currentOrgID := config.GetString(configuration.ORGANIZATION)
-	del := ictx.GetConfiguration().GetBool(flagDelete)
+	del := config.GetBool(flagDelete)
+	options := pushOptions{
+		recreate:      config.GetBool(flagRecreate),
+		force:         config.GetBool(flagForce),
+		cachedArchive: prj.CachedArchive,
+	}
 
-	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	manifest := prj.Manifest()
+	profile, err := applyProfile(config, manifest)
 	if err != nil {
 		return nil, err
 	}
-	bundled, err := bundle.BuildBundle(bundle.NewDirReader(prj.Path()))
+	orgIDs := targetOrganizations(config, manifest, profile)
+	if len(orgIDs) == 0 {
+		return nil, fmt.Errorf("no organizations to push to")
+	}
+
+	if !del {
+		if config.GetBool(flagSkipTests) {
+			fmt.Fprintln(os.Stderr, "Skipping tests, the bundle is pushed untested.")
+		} else {
+			summary, err := test.Run(ctx, prj, test.Options{
+				Verbose: config.GetBool(configuration.DEBUG),
+			})
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Test summary: %s.\n", summary)
+			if !summary.Passed() {
+				return nil, fmt.Errorf("tests failed, refusing to push (use --%s to override)", flagSkipTests)
+			}
+		}
+	}
+
+	archive, prov, err := buildArchive(prj, logger)
 	if err != nil {
 		return nil, err
 	}
-	if err := bundled.Validate(); err != nil {
-		return nil, err
+	if !del {
+		fmt.Fprintf(os.Stderr, "Bundle provenance: %s\n", prov)
 	}
-	logger.Println("validated bundle")
-
-	targz := &bytes.Buffer{}
-	if err := bundle.NewTarGzWriter(targz).Write(bundled); err != nil {
+	digest, err := bundleDigest(archive)
+	if err != nil {
 		return nil, err
 	}
+	var sig *signing.Signature
+	if keyPath := signingKeyPath(config, prj); keyPath != "" && !del {
+		signed, err := signArchive(prj.FS, keyPath, archive)
+		if err != nil {
+			return nil, err
+		}
+		sig = &signed
+		fmt.Fprintf(os.Stderr, "Signed bundle with key %s.\n", sig.PublicKeyFingerprint)
+	}
 
 	client := service.NewClient(
 		ictx.GetNetworkAccess().GetHttpClient(),
 		config.GetString(configuration.API_URL),
 		config.GetBool(constants.FF_IAC_NEW_ENGINE),
 	)
-	manifest := prj.Manifest()
-	push := getManifestPushByOrganization(manifest, currentOrgID)
-	if push == nil && del {
-		return nil, fmt.Errorf("no rule bundle to delete")
-	} else if push == nil {
-		logger.Println("uploading new custom rules bundle")
-		customRulesID, err := client.CreateCustomRules(ctx, currentOrgID, targz.Bytes())
+
+	// A failure in one organization should not prevent pushing to the
+	// others, so we collect the results and only write the manifest once all
+	// organizations have been processed.
+	var failed int
+	for _, orgID := range orgIDs {
+		var err error
+		if del {
+			err = deleteFromOrganization(ctx, client, &manifest, orgID, logger)
+		} else {
+			var customRulesID string
+			customRulesID, err = pushToOrganization(ctx, client, &manifest, orgID, archive, digest, options, logger)
+			if err == nil {
+				prj.RecordPush(project.HistoryEntry{
+					Timestamp:      time.Now().UTC(),
+					Digest:         digest,
+					GitCommit:      prov.GitCommit,
+					OrganizationID: orgID,
+					CustomRulesID:  customRulesID,
+				})
+			}
+		}
 		if err != nil {
-			return nil, err
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed for organization %s: %s\n", orgID, err)
+			if hint := errorHint(err); hint != "" {
+				fmt.Fprintln(os.Stderr, hint)
+			}
+		} else if del {
+			fmt.Fprintf(os.Stderr, "Successfully deleted custom rule bundle from organization %s.\n", orgID)
+		} else {
+			fmt.Fprintf(os.Stderr, "Successfully uploaded custom rule bundle to organization %s.\n", orgID)
 		}
+	}
 
-		manifest.Push = append(manifest.Push, project.ManifestPush{
-			CustomRulesID:  customRulesID,
-			OrganizationID: currentOrgID,
-		})
-		prj.UpdateManifest(manifest)
-		if err := prj.WriteChanges(); err != nil {
+	if !del && failed < len(orgIDs) {
+		if err := cacheArchive(prj, digest, archive, sig, logger); err != nil {
 			return nil, err
 		}
-	} else if del {
-		logger.Println("deleting custom rules bundle", push.CustomRulesID)
-		err := client.DeleteCustomRules(ctx, push.OrganizationID, push.CustomRulesID)
+	}
+	prj.UpdateManifest(manifest)
+	if err := prj.WriteChanges(); err != nil {
+		return nil, err
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("failed for %d of %d organizations", failed, len(orgIDs))
+	}
+	return []workflow.Data{}, nil
+}
+
+// cacheArchive stores the pushed archive and its signature, if any, in the
+// local archive cache. A bundle with the same digest that was pushed before is
+// kept along with its signature.
+func cacheArchive(prj *project.Project, digest string, archive []byte, sig *signing.Signature, logger *log.Logger) error {
+	cached, err := prj.HasCachedArchive(digest)
+	if err != nil {
+		return err
+	}
+	if cached {
+		logger.Println("bundle archive", digest, "is already cached")
+		return nil
+	}
+	path := prj.CacheArchive(digest, archive)
+	logger.Println("cached bundle archive", digest, "at", path)
+	if sig != nil {
+		b, err := sig.Marshal()
 		if err != nil {
-			return nil, err
+			return err
+		}
+		path := prj.CacheArchiveSignature(digest, b)
+		logger.Println("stored bundle signature at", path)
+	}
+	return nil
+}
+
+// errorHint returns advice for API errors that users commonly run into, or an
+// empty string.
+func errorHint(err error) string {
+	switch {
+	case service.IsForbidden(err):
+		return "Check that the organization is entitled to custom rules and that your token is allowed to manage them."
+	case service.IsNotFound(err):
+		return fmt.Sprintf(
+			"The rule bundle recorded in the project manifest no longer exists. Push with --%s to create a new one.",
+			flagRecreate,
+		)
+	}
+	return ""
+}
+
+// applyProfile looks up the profile selected with --profile and overrides the
+// API URL and feature flag in the configuration with the profile's values.
+func applyProfile(config configuration.Configuration, manifest project.Manifest) (project.ManifestProfile, error) {
+	name := config.GetString(flagProfile)
+	if name == "" {
+		return project.ManifestProfile{}, nil
+	}
+	profile, ok := manifest.Profile(name)
+	if !ok {
+		return project.ManifestProfile{}, fmt.Errorf("profile %s not found in the project manifest", name)
+	}
+	if profile.APIURL != "" {
+		config.Set(configuration.API_URL, profile.APIURL)
+	}
+	if profile.IacNewEngine != nil {
+		config.Set(constants.FF_IAC_NEW_ENGINE, *profile.IacNewEngine)
+	}
+	return profile, nil
+}
+
+// targetOrganizations returns the organizations that this invocation should
+// push to. Organizations given with --orgs take precedence over the ones from
+// the selected profile, which take precedence over the current organization.
+// --all-orgs adds every organization from the manifest, limited to the
+// profile's organizations when a profile is selected.
+//
+// The current organization is the CLI's global --org, which is resolved from
+// a slug to an ID and falls back to the configured or default organization.
+func targetOrganizations(
+	config configuration.Configuration,
+	manifest project.Manifest,
+	profile project.ManifestProfile,
+) []string {
+	orgIDs := config.GetStringSlice(flagOrgs)
+	if len(orgIDs) == 0 {
+		// Values from the environment or config file are plain strings.
+		orgIDs = []string{config.GetString(flagOrgs)}
+	}
+	orgIDs = dedupe(splitOrganizations(orgIDs))
+	if len(orgIDs) == 0 {
+		orgIDs = append(orgIDs, profile.OrganizationIDs...)
+	}
+	if config.GetBool(flagAllOrgs) {
+		profileSelected := config.GetString(flagProfile) != ""
+		for _, push := range manifest.Push {
+			if profileSelected && !slices.Contains(profile.OrganizationIDs, push.OrganizationID) {
+				continue
+			}
+			orgIDs = append(orgIDs, push.OrganizationID)
+		}
+	}
+	if len(orgIDs) == 0 {
+		if currentOrgID := config.GetString(configuration.ORGANIZATION); currentOrgID != "" {
+			orgIDs = append(orgIDs, currentOrgID)
 		}
+	}
+	return dedupe(orgIDs)
+}
+
+// pushOptions controls how an existing upstream bundle is treated.
+type pushOptions struct {
+	// recreate forces a new bundle to be created.
+	recreate bool
+	// force overwrites the upstream bundle even if it was changed since it was
+	// last pushed from this project.
+	force bool
+	// cachedArchive returns the archive of an earlier push by its digest. It
+	// is used to restore a bundle that was deleted to re-create it.
+	cachedArchive func(digest string) ([]byte, error)
+}
 
-		filtered := []project.ManifestPush{}
-		for _, p := range manifest.Push {
-			if p.OrganizationID != currentOrgID {
-				filtered = append(filtered, p)
+// pushToOrganization creates or updates the rule bundle for a single
+// organization and records the pushed bundle and its digest in the manifest.
+// It returns the ID of the rule bundle. Bundles that were deleted upstream are
+// created again.
+func pushToOrganization(
+	ctx context.Context,
+	client *service.Client,
+	manifest *project.Manifest,
+	orgID string,
+	targz []byte,
+	digest string,
+	options pushOptions,
+	logger *log.Logger,
+) (string, error) {
+	push := getManifestPushByOrganization(*manifest, orgID)
+	if push != nil && options.recreate {
+		return recreateBundle(ctx, client, manifest, *push, targz, digest, options, logger)
+	} else if push != nil {
+		updated, err := updateBundle(ctx, client, *push, targz, options.force, logger)
+		if !service.IsNotFound(err) {
+			if err != nil {
+				return "", err
 			}
+			setManifestPush(manifest, project.ManifestPush{
+				CustomRulesID:  push.CustomRulesID,
+				OrganizationID: orgID,
+				Digest:         digest,
+				ETag:           updated.ETag,
+			})
+			return push.CustomRulesID, nil
 		}
-		manifest.Push = filtered
-		prj.UpdateManifest(manifest)
-		if err := prj.WriteChanges(); err != nil {
-			return nil, err
+		fmt.Fprintf(
+			os.Stderr,
+			"Custom rule bundle %s no longer exists in organization %s, creating a new one.\n",
+			push.CustomRulesID,
+			orgID,
+		)
+	}
+
+	logger.Println("uploading new custom rules bundle to", orgID)
+	created, err := client.CreateCustomRules(ctx, orgID, targz)
+	if err != nil {
+		return "", err
+	}
+	setManifestPush(manifest, project.ManifestPush{
+		CustomRulesID:  created.ID,
+		OrganizationID: orgID,
+		Digest:         digest,
+		ETag:           created.ETag,
+	})
+	return created.ID, nil
+}
+
+// recreateBundle replaces an existing bundle with a new one. The new bundle is
+// created before the old one is deleted, so that a failed upload leaves the
+// old bundle in place. If the organization can only have one bundle, the old
+// one is deleted first and restored from the archive cache if the upload
+// fails.
+func recreateBundle(
+	ctx context.Context,
+	client *service.Client,
+	manifest *project.Manifest,
+	push project.ManifestPush,
+	targz []byte,
+	digest string,
+	options pushOptions,
+	logger *log.Logger,
+) (string, error) {
+	logger.Println("uploading new custom rules bundle to", push.OrganizationID, "to replace", push.CustomRulesID)
+	created, err := client.CreateCustomRules(ctx, push.OrganizationID, targz)
+	switch {
+	case err == nil:
+		logger.Println("deleting previous custom rules bundle", push.CustomRulesID)
+		if err := client.DeleteCustomRules(ctx, push.OrganizationID, push.CustomRulesID); err != nil && !service.IsNotFound(err) {
+			// The new bundle is in place, only the old one is left over.
+			fmt.Fprintf(
+				os.Stderr,
+				"Warning: failed to delete the previous custom rule bundle %s from organization %s: %s\n",
+				push.CustomRulesID,
+				push.OrganizationID,
+				err,
+			)
 		}
-	} else {
-		logger.Println("updating existing custom rules bundle", push.CustomRulesID)
-		err := client.UpdateCustomRules(ctx, push.OrganizationID, push.CustomRulesID, targz.Bytes())
+	case service.IsConflict(err):
+		logger.Println("organization", push.OrganizationID, "only allows one bundle, deleting", push.CustomRulesID, "first")
+		if err := client.DeleteCustomRules(ctx, push.OrganizationID, push.CustomRulesID); err != nil && !service.IsNotFound(err) {
+			return "", err
+		}
+		created, err = client.CreateCustomRules(ctx, push.OrganizationID, targz)
+		if err != nil {
+			return "", restoreBundle(ctx, client, manifest, push, options, err)
+		}
+	default:
+		return "", err
+	}
+	setManifestPush(manifest, project.ManifestPush{
+		CustomRulesID:  created.ID,
+		OrganizationID: push.OrganizationID,
+		Digest:         digest,
+		ETag:           created.ETag,
+	})
+	return created.ID, nil
+}
+
+// restoreBundle uploads the cached archive of a bundle that was deleted to be
+// re-created, after creating its replacement failed with createErr. The
+// returned error says whether the bundle was restored.
+func restoreBundle(
+	ctx context.Context,
+	client *service.Client,
+	manifest *project.Manifest,
+	push project.ManifestPush,
+	options pushOptions,
+	createErr error,
+) error {
+	if options.cachedArchive == nil || push.Digest == "" {
+		return fmt.Errorf(
+			"%w\nthe previous custom rule bundle %s was deleted and there is no cached archive to restore it from",
+			createErr,
+			push.CustomRulesID,
+		)
+	}
+	archive, err := options.cachedArchive(push.Digest)
+	if err == nil {
+		var restored *service.CustomRules
+		restored, err = client.CreateCustomRules(ctx, push.OrganizationID, archive)
+		if err == nil {
+			setManifestPush(manifest, project.ManifestPush{
+				CustomRulesID:  restored.ID,
+				OrganizationID: push.OrganizationID,
+				Digest:         push.Digest,
+				ETag:           restored.ETag,
+			})
+			return fmt.Errorf(
+				"%w\nthe previous custom rule bundle (%s) was restored from the archive cache as %s",
+				createErr,
+				push.Digest,
+				restored.ID,
+			)
+		}
+	}
+	return fmt.Errorf(
+		"%w\nthe previous custom rule bundle %s was deleted and could not be restored from the archive cache: %s",
+		createErr,
+		push.CustomRulesID,
+		err,
+	)
+}
+
+// updateBundle uploads a new version of an existing bundle. Unless force is
+// set, it refuses to overwrite a bundle that was changed upstream since it was
+// last pushed from this project. The API does not return bundle contents, so
+// the check relies on ETags; if there is none to compare, it also refuses to
+// overwrite the bundle.
+func updateBundle(
+	ctx context.Context,
+	client *service.Client,
+	push project.ManifestPush,
+	targz []byte,
+	force bool,
+	logger *log.Logger,
+) (*service.CustomRules, error) {
+	var ifMatch string
+	if !force {
+		upstream, err := client.GetCustomRules(ctx, push.OrganizationID, push.CustomRulesID)
 		if err != nil {
 			return nil, err
 		}
+		if push.ETag == "" || upstream.ETag == "" {
+			return nil, fmt.Errorf(
+				"could not check whether custom rule bundle %s was changed upstream since it was last pushed "+
+					"from this project because no ETag is available. Use --%s to overwrite it",
+				push.CustomRulesID,
+				flagForce,
+			)
+		}
+		if upstream.ETag != push.ETag {
+			return nil, errChangedUpstream(push)
+		}
+		ifMatch = push.ETag
 	}
 
-	fmt.Fprintln(os.Stderr, "Successfully uploaded custom rule bundle.")
-	return []workflow.Data{}, nil
+	logger.Println("updating existing custom rules bundle", push.CustomRulesID)
+	updated, err := client.UpdateCustomRules(ctx, push.OrganizationID, push.CustomRulesID, ifMatch, targz)
+	if service.IsPreconditionFailed(err) {
+		// Someone else pushed between our check and the update.
+		return nil, errChangedUpstream(push)
+	}
+	return updated, err
+}
+
+func errChangedUpstream(push project.ManifestPush) error {
+	last := "from this project"
+	if push.Digest != "" {
+		last = fmt.Sprintf("from this project (%s)", push.Digest)
+	}
+	return fmt.Errorf(
+		"custom rule bundle %s was changed upstream since it was last pushed %s. "+
+			"Pull the latest project changes before pushing, or use --%s to overwrite it",
+		push.CustomRulesID,
+		last,
+		flagForce,
+	)
+}
+
+// deleteFromOrganization deletes the rule bundle for a single organization and
+// removes it from the manifest. Bundles that were already deleted upstream are
+// only removed from the manifest.
+func deleteFromOrganization(
+	ctx context.Context,
+	client *service.Client,
+	manifest *project.Manifest,
+	orgID string,
+	logger *log.Logger,
+) error {
+	push := getManifestPushByOrganization(*manifest, orgID)
+	if push == nil {
+		return fmt.Errorf("no rule bundle to delete")
+	}
+	logger.Println("deleting custom rules bundle", push.CustomRulesID)
+	if err := client.DeleteCustomRules(ctx, push.OrganizationID, push.CustomRulesID); err != nil {
+		if !service.IsNotFound(err) {
+			return err
+		}
+		logger.Println("custom rules bundle", push.CustomRulesID, "was already deleted")
+	}
+	filtered := []project.ManifestPush{}
+	for _, p := range manifest.Push {
+		if p.OrganizationID != orgID {
+			filtered = append(filtered, p)
+		}
+	}
+	manifest.Push = filtered
+	return nil
+}
+
+// setManifestPush replaces the manifest entry for the organization of the
+// given push, or adds it if there is none.
+func setManifestPush(manifest *project.Manifest, push project.ManifestPush) {
+	for i := range manifest.Push {
+		if manifest.Push[i].OrganizationID == push.OrganizationID {
+			manifest.Push[i] = push
+			return
+		}
+	}
+	manifest.Push = append(manifest.Push, push)
 }
 
 func getManifestPushByOrganization(manifest project.Manifest, organizationID string) *project.ManifestPush {
@@ -140,4 +577,29 @@ func getManifestPushByOrganization(manifest project.Manifest, organizationID str
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// splitOrganizations splits comma-separated organization IDs, which is how
+// several organizations are given when --orgs is set as a single value.
+func splitOrganizations(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, orgID := range strings.Split(v, ",") {
+			out = append(out, strings.TrimSpace(orgID))
+		}
+	}
+	return out
+}
+
+func dedupe(values []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,615 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/go-application-framework/pkg/networking"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/service"
+	"github.com/snyk/cli-extension-iac-rules/internal/service/servicetest"
+	"github.com/snyk/cli-extension-iac-rules/internal/signing"
+)
+
+const testOrgID = "org-1"
+
+var testLogger = log.New(io.Discard, "", 0)
+
+// countingFs counts how often files with the given base name are opened for
+// writing.
+type countingFs struct {
+	afero.Fs
+	name   string
+	writes int
+}
+
+func (fsys *countingFs) Create(name string) (afero.File, error) {
+	if filepath.Base(name) == fsys.name {
+		fsys.writes++
+	}
+	return fsys.Fs.Create(name)
+}
+
+func (fsys *countingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if filepath.Base(name) == fsys.name && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		fsys.writes++
+	}
+	return fsys.Fs.OpenFile(name, flag, perm)
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	defer f.Close()
+	stderr := os.Stderr
+	os.Stderr = f
+	defer func() { os.Stderr = stderr }()
+	fn()
+	b, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestPushWorkflow(t *testing.T) {
+	orgIDs := []string{"org-1", "org-2", "org-3"}
+
+	testCases := []struct {
+		name          string
+		setup         func(s *servicetest.Server)
+		expectedError string
+		expectedOrgs  []string
+		failedOrgs    []string
+	}{
+		{
+			name:         "pushes to every organization",
+			setup:        func(s *servicetest.Server) {},
+			expectedOrgs: orgIDs,
+		},
+		{
+			name: "keeps pushing when one organization fails",
+			setup: func(s *servicetest.Server) {
+				s.FailNext(http.MethodPost, http.StatusForbidden, 1, nil)
+			},
+			expectedError: "failed for 1 of 3 organizations",
+			expectedOrgs:  []string{"org-2", "org-3"},
+			failedOrgs:    []string{"org-1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := servicetest.NewServer()
+			defer server.Close()
+			tc.setup(server)
+
+			dir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "rules", "TEST_001"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name":"Test"}`), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "rules", "TEST_001", "main.rego"), []byte("package rules.TEST_001\n"), 0644))
+			fsys := &countingFs{Fs: afero.NewOsFs(), name: "manifest.json"}
+			prj, err := project.FromDir(fsys, dir)
+			require.NoError(t, err)
+
+			config := configuration.NewInMemory()
+			config.Set(configuration.API_URL, server.URL)
+			config.AddDefaultValue(configuration.ORGANIZATION, defaultOrganization)
+			config.Set(flagOrgs, orgIDs)
+			config.Set(flagSkipTests, true)
+			ictx := workflow.NewInvocationContext(
+				workflow.NewWorkflowIdentifier("iac.rules.push"),
+				config,
+				nil,
+				networking.NewNetworkAccess(config),
+				zerolog.Nop(),
+				nil,
+				nil,
+			)
+
+			var pushErr error
+			output := captureStderr(t, func() {
+				_, pushErr = pushProject(ictx, prj)
+			})
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, pushErr, tc.expectedError)
+			} else {
+				require.NoError(t, pushErr)
+			}
+			for _, orgID := range tc.expectedOrgs {
+				assert.Contains(t, output, "Successfully uploaded custom rule bundle to organization "+orgID+".")
+			}
+			for _, orgID := range tc.failedOrgs {
+				assert.Contains(t, output, "Failed for organization "+orgID+":")
+				assert.Empty(t, server.Bundles(orgID))
+			}
+			assert.Equal(t, 1, fsys.writes)
+
+			written, err := project.FromDir(afero.NewOsFs(), dir)
+			require.NoError(t, err)
+			manifest := written.Manifest()
+			require.Len(t, manifest.Push, len(tc.expectedOrgs))
+			history := written.History()
+			require.Len(t, history, len(tc.expectedOrgs))
+			for i, orgID := range tc.expectedOrgs {
+				bundles := server.Bundles(orgID)
+				require.Len(t, bundles, 1)
+				push := manifest.Push[i]
+				assert.Equal(t, orgID, push.OrganizationID)
+				assert.Equal(t, bundles[0].ID, push.CustomRulesID)
+				assert.Equal(t, bundles[0].ETag, push.ETag)
+				digest, err := bundleDigest(bundles[0].Contents)
+				require.NoError(t, err)
+				assert.Equal(t, digest, push.Digest)
+
+				assert.Equal(t, orgID, history[i].OrganizationID)
+				assert.Equal(t, bundles[0].ID, history[i].CustomRulesID)
+				assert.Equal(t, digest, history[i].Digest)
+			}
+			cached, err := written.HasCachedArchive(history[0].Digest)
+			require.NoError(t, err)
+			assert.True(t, cached)
+		})
+	}
+}
+
+func TestPushToOrganization(t *testing.T) {
+	v1 := []byte("bundle v1")
+	v2 := []byte("bundle v2")
+
+	testCases := []struct {
+		name string
+		// setup seeds the server and returns the manifest entries of the
+		// project before pushing.
+		setup           func(s *servicetest.Server) []project.ManifestPush
+		options         pushOptions
+		iacNewEngine    bool
+		expectedError   string
+		expectedBundles int
+	}{
+		{
+			name: "creates a new bundle",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				return nil
+			},
+			expectedBundles: 1,
+		},
+		{
+			name: "creates a new bundle with the new engine",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				return nil
+			},
+			iacNewEngine:    true,
+			expectedBundles: 1,
+		},
+		{
+			name: "updates an existing bundle",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				b := s.AddBundle(testOrgID, v1)
+				return []project.ManifestPush{{
+					CustomRulesID:  b.ID,
+					OrganizationID: testOrgID,
+					Digest:         "sha256:v1",
+					ETag:           b.ETag,
+				}}
+			},
+			expectedBundles: 1,
+		},
+		{
+			name: "refuses to update a bundle pushed before ETags were recorded",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				b := s.AddBundle(testOrgID, v1)
+				return []project.ManifestPush{{CustomRulesID: b.ID, OrganizationID: testOrgID}}
+			},
+			expectedError:   "no ETag is available",
+			expectedBundles: 1,
+		},
+		{
+			name: "overwrites a bundle pushed before ETags were recorded when forced",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				b := s.AddBundle(testOrgID, v1)
+				return []project.ManifestPush{{CustomRulesID: b.ID, OrganizationID: testOrgID}}
+			},
+			options:         pushOptions{force: true},
+			expectedBundles: 1,
+		},
+		{
+			name: "re-creates a bundle that was deleted upstream",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				return []project.ManifestPush{{CustomRulesID: "deleted", OrganizationID: testOrgID}}
+			},
+			expectedBundles: 1,
+		},
+		{
+			name: "re-creates a bundle when asked to",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				b := s.AddBundle(testOrgID, v1)
+				return []project.ManifestPush{{CustomRulesID: b.ID, OrganizationID: testOrgID}}
+			},
+			options:         pushOptions{recreate: true},
+			expectedBundles: 1,
+		},
+		{
+			name: "keeps the old bundle when re-creating it fails",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				b := s.AddBundle(testOrgID, v1)
+				s.FailNext(http.MethodPost, http.StatusForbidden, 1, nil)
+				return []project.ManifestPush{{CustomRulesID: b.ID, OrganizationID: testOrgID}}
+			},
+			options:         pushOptions{recreate: true},
+			expectedError:   "injected failure",
+			expectedBundles: 1,
+		},
+		{
+			name: "re-creates a bundle in an organization with a single bundle",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				s.LimitOneBundle()
+				b := s.AddBundle(testOrgID, v1)
+				return []project.ManifestPush{{CustomRulesID: b.ID, OrganizationID: testOrgID}}
+			},
+			options:         pushOptions{recreate: true},
+			expectedBundles: 1,
+		},
+		{
+			name: "refuses to overwrite a bundle changed upstream",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				b := s.AddBundle(testOrgID, v1)
+				push := project.ManifestPush{CustomRulesID: b.ID, OrganizationID: testOrgID, ETag: b.ETag}
+				// Someone else pushes in the meantime.
+				s.ReplaceBundle(b.ID, []byte("bundle from elsewhere"))
+				return []project.ManifestPush{push}
+			},
+			expectedError:   "was changed upstream",
+			expectedBundles: 1,
+		},
+		{
+			name: "surfaces API errors",
+			setup: func(s *servicetest.Server) []project.ManifestPush {
+				s.FailNext(http.MethodPost, http.StatusForbidden, 1, nil)
+				return nil
+			},
+			expectedError:   "injected failure",
+			expectedBundles: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := servicetest.NewServer()
+			defer server.Close()
+			client := service.NewClient(server.Client(), server.URL, tc.iacNewEngine)
+			manifest := project.Manifest{Push: tc.setup(server)}
+
+			id, err := pushToOrganization(context.Background(), client, &manifest, testOrgID, v2, "sha256:v2", tc.options, testLogger)
+			bundles := server.Bundles(testOrgID)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.Len(t, bundles, tc.expectedBundles)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, bundles, tc.expectedBundles)
+			assert.Equal(t, id, bundles[0].ID)
+			assert.Equal(t, v2, bundles[0].Contents)
+			assert.Equal(t, tc.iacNewEngine, bundles[0].Hidden)
+			assert.Equal(t, []project.ManifestPush{{
+				CustomRulesID:  id,
+				OrganizationID: testOrgID,
+				Digest:         "sha256:v2",
+				ETag:           bundles[0].ETag,
+			}}, manifest.Push)
+		})
+	}
+}
+
+func TestRecreateBundleRestore(t *testing.T) {
+	v1 := []byte("bundle v1")
+	testCases := []struct {
+		name          string
+		cachedArchive func(digest string) ([]byte, error)
+		expectedError string
+		restored      bool
+	}{
+		{
+			name: "restores the previous bundle from the archive cache",
+			cachedArchive: func(digest string) ([]byte, error) {
+				assert.Equal(t, "sha256:v1", digest)
+				return v1, nil
+			},
+			expectedError: "was restored from the archive cache as",
+			restored:      true,
+		},
+		{
+			name: "reports a previous bundle missing from the archive cache",
+			cachedArchive: func(digest string) ([]byte, error) {
+				return nil, project.ErrArchiveNotCached
+			},
+			expectedError: "could not be restored from the archive cache",
+		},
+		{
+			name:          "reports a previous bundle without archive cache",
+			expectedError: "there is no cached archive to restore it from",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := servicetest.NewServer()
+			defer server.Close()
+			client := service.NewClient(server.Client(), server.URL, false)
+			b := server.AddBundle(testOrgID, v1)
+			manifest := project.Manifest{Push: []project.ManifestPush{{
+				CustomRulesID:  b.ID,
+				OrganizationID: testOrgID,
+				Digest:         "sha256:v1",
+			}}}
+			// The organization only allows one bundle, and uploading the new
+			// one after deleting the old one fails.
+			server.FailNext(http.MethodPost, http.StatusConflict, 1, nil)
+			server.FailNext(http.MethodPost, http.StatusBadRequest, 1, nil)
+
+			options := pushOptions{recreate: true, cachedArchive: tc.cachedArchive}
+			_, err := pushToOrganization(context.Background(), client, &manifest, testOrgID, []byte("bundle v2"), "sha256:v2", options, testLogger)
+			assert.ErrorContains(t, err, "injected failure")
+			assert.ErrorContains(t, err, tc.expectedError)
+			bundles := server.Bundles(testOrgID)
+			if !tc.restored {
+				assert.Empty(t, bundles)
+				return
+			}
+			require.Len(t, bundles, 1)
+			assert.Equal(t, v1, bundles[0].Contents)
+			assert.Equal(t, []project.ManifestPush{{
+				CustomRulesID:  bundles[0].ID,
+				OrganizationID: testOrgID,
+				Digest:         "sha256:v1",
+				ETag:           bundles[0].ETag,
+			}}, manifest.Push)
+		})
+	}
+}
+
+func TestUpdateBundleConcurrentPush(t *testing.T) {
+	server := servicetest.NewServer()
+	defer server.Close()
+	client := service.NewClient(server.Client(), server.URL, false)
+
+	b := server.AddBundle(testOrgID, []byte("bundle v1"))
+	push := project.ManifestPush{CustomRulesID: b.ID, OrganizationID: testOrgID, ETag: b.ETag}
+
+	// Another push lands after the ETag check but before the update.
+	server.FailNext(http.MethodPatch, http.StatusPreconditionFailed, 1, nil)
+	_, err := updateBundle(context.Background(), client, push, []byte("bundle v2"), false, testLogger)
+	assert.ErrorContains(t, err, "was changed upstream")
+
+	updated, err := updateBundle(context.Background(), client, push, []byte("bundle v2"), true, testLogger)
+	require.NoError(t, err)
+	stored, ok := server.Bundle(testOrgID, b.ID)
+	require.True(t, ok)
+	assert.Equal(t, stored.ETag, updated.ETag)
+	assert.Equal(t, []byte("bundle v2"), stored.Contents)
+	var ifMatch []string
+	for _, r := range server.Requests() {
+		if r.Method == http.MethodPatch {
+			ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+		}
+	}
+	assert.Equal(t, []string{b.ETag, ""}, ifMatch)
+}
+
+func TestUpdateBundleWithoutETags(t *testing.T) {
+	testCases := []struct {
+		name string
+		etag string
+	}{
+		{
+			name: "manifest without ETag",
+		},
+		{
+			name: "API without ETags",
+			etag: `"stale"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := servicetest.NewServer()
+			defer server.Close()
+			server.OmitETags()
+			client := service.NewClient(server.Client(), server.URL, false)
+
+			b := server.AddBundle(testOrgID, []byte("bundle v1"))
+			// Someone else pushes in the meantime, which can't be detected.
+			server.ReplaceBundle(b.ID, []byte("bundle from elsewhere"))
+			push := project.ManifestPush{CustomRulesID: b.ID, OrganizationID: testOrgID, ETag: tc.etag}
+
+			_, err := updateBundle(context.Background(), client, push, []byte("bundle v2"), false, testLogger)
+			assert.ErrorContains(t, err, "no ETag is available")
+			stored, ok := server.Bundle(testOrgID, b.ID)
+			require.True(t, ok)
+			assert.Equal(t, []byte("bundle from elsewhere"), stored.Contents)
+
+			updated, err := updateBundle(context.Background(), client, push, []byte("bundle v2"), true, testLogger)
+			require.NoError(t, err)
+			assert.Empty(t, updated.ETag)
+			stored, ok = server.Bundle(testOrgID, b.ID)
+			require.True(t, ok)
+			assert.Equal(t, []byte("bundle v2"), stored.Contents)
+			for _, r := range server.Requests() {
+				assert.Empty(t, r.Header.Get("If-Match"))
+			}
+		})
+	}
+}
+
+func TestDeleteFromOrganization(t *testing.T) {
+	server := servicetest.NewServer()
+	defer server.Close()
+	client := service.NewClient(server.Client(), server.URL, false)
+
+	b := server.AddBundle(testOrgID, []byte("bundle"))
+	manifest := project.Manifest{Push: []project.ManifestPush{
+		{CustomRulesID: b.ID, OrganizationID: testOrgID},
+		{CustomRulesID: "deleted", OrganizationID: "org-2"},
+	}}
+
+	require.NoError(t, deleteFromOrganization(context.Background(), client, &manifest, testOrgID, testLogger))
+	assert.Empty(t, server.Bundles(testOrgID))
+
+	// Bundles deleted upstream are only removed from the manifest.
+	require.NoError(t, deleteFromOrganization(context.Background(), client, &manifest, "org-2", testLogger))
+	assert.Empty(t, manifest.Push)
+
+	assert.Error(t, deleteFromOrganization(context.Background(), client, &manifest, testOrgID, testLogger))
+}
+
+func TestCacheArchive(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	prj, err := project.FromDir(fsys, "prj")
+	require.NoError(t, err)
+	digest := "sha256:0123456789abcdef"
+	first := &signing.Signature{PublicKeyFingerprint: "first"}
+	require.NoError(t, cacheArchive(prj, digest, []byte("first build"), first, testLogger))
+	require.NoError(t, prj.WriteChanges())
+
+	// A rebuild of the same sources keeps the archive and signature that
+	// were cached first.
+	prj, err = project.FromDir(fsys, "prj")
+	require.NoError(t, err)
+	second := &signing.Signature{PublicKeyFingerprint: "second"}
+	require.NoError(t, cacheArchive(prj, digest, []byte("second build"), second, testLogger))
+	require.NoError(t, prj.WriteChanges())
+
+	cached, err := prj.CachedArchive(digest)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first build"), cached)
+	sig, err := signing.ReadSignature(fsys, "prj/.bundles/0123456789abcdef.tar.gz.sig")
+	require.NoError(t, err)
+	assert.Equal(t, "first", sig.PublicKeyFingerprint)
+}
+
+// defaultOrganization mimics the CLI's default for the global --org: slugs
+// are resolved to IDs, and anything that isn't a non-empty string is replaced
+// by the user's default organization.
+func defaultOrganization(existing interface{}) (interface{}, error) {
+	if org, ok := existing.(string); ok && org != "" {
+		if org == "my-org" {
+			return "my-org-id", nil
+		}
+		return org, nil
+	}
+	return "default", nil
+}
+
+func TestTargetOrganizations(t *testing.T) {
+	manifest := project.Manifest{Push: []project.ManifestPush{
+		{OrganizationID: "org-1"},
+		{OrganizationID: "org-2"},
+	}}
+	profile := project.ManifestProfile{OrganizationIDs: []string{"org-3"}}
+	testCases := []struct {
+		name     string
+		set      map[string]interface{}
+		profile  project.ManifestProfile
+		expected []string
+	}{
+		{
+			name:     "default organization",
+			expected: []string{"default"},
+		},
+		{
+			name:     "global --org",
+			set:      map[string]interface{}{configuration.ORGANIZATION: "org-1"},
+			expected: []string{"org-1"},
+		},
+		{
+			name:     "global --org slug",
+			set:      map[string]interface{}{configuration.ORGANIZATION: "my-org"},
+			expected: []string{"my-org-id"},
+		},
+		{
+			name:     "repeated --orgs",
+			set:      map[string]interface{}{flagOrgs: []string{"org-1", "org-3"}},
+			expected: []string{"org-1", "org-3"},
+		},
+		{
+			name:     "comma-separated --orgs",
+			set:      map[string]interface{}{flagOrgs: "org-1, org-3"},
+			expected: []string{"org-1", "org-3"},
+		},
+		{
+			name: "--orgs with a configured organization",
+			set: map[string]interface{}{
+				configuration.ORGANIZATION: "org-2",
+				flagOrgs:                   []string{"org-1", "org-3"},
+			},
+			expected: []string{"org-1", "org-3"},
+		},
+		{
+			name:     "profile",
+			profile:  profile,
+			expected: []string{"org-3"},
+		},
+		{
+			name:     "--orgs takes precedence over the profile",
+			set:      map[string]interface{}{flagOrgs: []string{"org-1"}},
+			profile:  profile,
+			expected: []string{"org-1"},
+		},
+		{
+			name:     "all organizations from the manifest",
+			set:      map[string]interface{}{flagOrgs: []string{"org-1", "org-3"}, flagAllOrgs: true},
+			expected: []string{"org-1", "org-3", "org-2"},
+		},
+		{
+			name: "all organizations from the manifest within the profile",
+			set: map[string]interface{}{
+				flagOrgs:    []string{"org-3"},
+				flagProfile: "prod",
+				flagAllOrgs: true,
+			},
+			profile:  project.ManifestProfile{OrganizationIDs: []string{"org-2", "org-3"}},
+			expected: []string{"org-3", "org-2"},
+		},
+		{
+			name:     "all organizations from the manifest with a profile without organizations",
+			set:      map[string]interface{}{flagProfile: "staging", flagAllOrgs: true},
+			expected: []string{"default"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configuration.NewInMemory()
+			config.AddDefaultValue(configuration.ORGANIZATION, defaultOrganization)
+			for key, value := range tc.set {
+				config.Set(key, value)
+			}
+			assert.Equal(t, tc.expected, targetOrganizations(config, manifest, tc.profile))
+		})
+	}
+}
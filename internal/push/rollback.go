@@ -0,0 +1,175 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/constants"
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/service"
+)
+
+func registerRollbackWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.rollback")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-rollback", pflag.ExitOnError)
+
+	flagset.String(flagTo, "", "Digest of the bundle to roll back to (defaults to the previously pushed bundle)")
+	addTargetFlags(flagset)
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, rollbackWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func rollbackWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	logger := ictx.GetLogger()
+	config := ictx.GetConfiguration()
+	to := config.GetString(flagTo)
+
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
+	manifest := prj.Manifest()
+	profile, err := applyProfile(config, manifest)
+	if err != nil {
+		return nil, err
+	}
+	orgIDs := targetOrganizations(config, manifest, profile)
+	if len(orgIDs) == 0 {
+		return nil, fmt.Errorf("no organizations to roll back")
+	}
+
+	client := service.NewClient(
+		ictx.GetNetworkAccess().GetHttpClient(),
+		config.GetString(configuration.API_URL),
+		config.GetBool(constants.FF_IAC_NEW_ENGINE),
+	)
+
+	history := prj.History()
+	var failed int
+	for _, orgID := range orgIDs {
+		entry, err := rollbackTarget(history, orgID, to)
+		if err == nil {
+			err = rollbackOrganization(ctx, client, prj, &manifest, entry, logger)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed for organization %s: %s\n", orgID, err)
+			if hint := errorHint(err); hint != "" {
+				fmt.Fprintln(os.Stderr, hint)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Successfully rolled back organization %s to %s.\n", orgID, entry.Digest)
+		}
+	}
+
+	prj.UpdateManifest(manifest)
+	if err := prj.WriteChanges(); err != nil {
+		return nil, err
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("failed for %d of %d organizations", failed, len(orgIDs))
+	}
+	return []workflow.Data{}, nil
+}
+
+// rollbackTarget returns the history entry to roll back to for the given
+// organization. When no digest is given, this is the most recent push of a
+// bundle that differs from the one that is currently deployed.
+func rollbackTarget(history []project.HistoryEntry, orgID string, digest string) (project.HistoryEntry, error) {
+	var entries []project.HistoryEntry
+	for _, e := range history {
+		if e.OrganizationID == orgID {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return project.HistoryEntry{}, fmt.Errorf("no pushes recorded for this organization")
+	}
+	if digest != "" {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].MatchesDigest(digest) {
+				return entries[i], nil
+			}
+		}
+		return project.HistoryEntry{}, fmt.Errorf("no push of bundle %s recorded for this organization", digest)
+	}
+	latest := entries[len(entries)-1]
+	for i := len(entries) - 2; i >= 0; i-- {
+		if entries[i].Digest != latest.Digest {
+			return entries[i], nil
+		}
+	}
+	return project.HistoryEntry{}, fmt.Errorf("no earlier bundle to roll back to")
+}
+
+// rollbackOrganization re-uploads the cached archive for the given history
+// entry and records the rollback as a new push. Rolling back deliberately
+// overwrites the upstream bundle, so it is not checked for upstream changes.
+func rollbackOrganization(
+	ctx context.Context,
+	client *service.Client,
+	prj *project.Project,
+	manifest *project.Manifest,
+	entry project.HistoryEntry,
+	logger *log.Logger,
+) error {
+	push := getManifestPushByOrganization(*manifest, entry.OrganizationID)
+	if push == nil {
+		return fmt.Errorf("no rule bundle for this organization in the manifest")
+	}
+	archive, err := prj.CachedArchive(entry.Digest)
+	if err != nil {
+		return err
+	}
+	logger.Println("re-uploading bundle", entry.Digest, "to custom rules bundle", push.CustomRulesID)
+	updated, err := client.UpdateCustomRules(ctx, push.OrganizationID, push.CustomRulesID, "", archive)
+	if err != nil {
+		return err
+	}
+	setManifestPush(manifest, project.ManifestPush{
+		CustomRulesID:  push.CustomRulesID,
+		OrganizationID: push.OrganizationID,
+		Digest:         entry.Digest,
+		ETag:           updated.ETag,
+	})
+	prj.RecordPush(project.HistoryEntry{
+		Timestamp:      time.Now().UTC(),
+		Digest:         entry.Digest,
+		GitCommit:      entry.GitCommit,
+		OrganizationID: push.OrganizationID,
+		CustomRulesID:  push.CustomRulesID,
+	})
+	return nil
+}
@@ -0,0 +1,78 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	history := []project.HistoryEntry{
+		{Digest: "sha256:aaaa", OrganizationID: "org-1"},
+		{Digest: "sha256:bbbb", OrganizationID: "org-1"},
+		{Digest: "sha256:cccc", OrganizationID: "org-2"},
+		{Digest: "sha256:bbbb", OrganizationID: "org-1"},
+	}
+	testCases := []struct {
+		name          string
+		orgID         string
+		digest        string
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:     "previous different bundle",
+			orgID:    "org-1",
+			expected: "sha256:aaaa",
+		},
+		{
+			name:     "explicit digest prefix",
+			orgID:    "org-1",
+			digest:   "bb",
+			expected: "sha256:bbbb",
+		},
+		{
+			name:          "unknown digest",
+			orgID:         "org-1",
+			digest:        "cccc",
+			expectedError: true,
+		},
+		{
+			name:          "no earlier bundle",
+			orgID:         "org-2",
+			expectedError: true,
+		},
+		{
+			name:          "no pushes",
+			orgID:         "org-3",
+			expectedError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, err := rollbackTarget(history, tc.orgID, tc.digest)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, entry.Digest)
+			}
+		})
+	}
+}
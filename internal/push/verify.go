@@ -0,0 +1,113 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/signing"
+)
+
+const (
+	flagArchive   = "archive"
+	flagPublicKey = "public-key"
+	flagSignature = "signature"
+)
+
+func registerVerifyWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.verify")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-verify", pflag.ExitOnError)
+
+	flagset.String(flagArchive, "bundle.tar.gz", "Path to the rule bundle archive to verify")
+	flagset.String(flagPublicKey, "", "Path to the ed25519 public key (PEM) to verify the bundle with")
+	flagset.String(flagSignature, "", "Path to the signature (defaults to the archive path with a .sig suffix)")
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, verifyWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func verifyWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	config := ictx.GetConfiguration()
+	archivePath := config.GetString(flagArchive)
+	sigPath := config.GetString(flagSignature)
+	if sigPath == "" {
+		sigPath = signing.SignaturePath(archivePath)
+	}
+	keyPath := config.GetString(flagPublicKey)
+
+	fsys := afero.NewOsFs()
+	if keyPath == "" {
+		prj, err := project.FromDir(fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		if path := prj.Manifest().VerificationKey; path != "" {
+			keyPath = filepath.Join(prj.Path(), path)
+		}
+	}
+	if keyPath == "" {
+		return nil, fmt.Errorf("no public key given, use --%s or set verification_key in the manifest", flagPublicKey)
+	}
+
+	publicKey, err := signing.LoadPublicKey(fsys, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := afero.ReadFile(fsys, archivePath)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signing.ReadSignature(fsys, sigPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := signing.Verify(publicKey, archive, sig); err != nil {
+		return nil, err
+	}
+	digest, err := bundleDigest(archive)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(
+		os.Stderr,
+		"Verified bundle %s (%s) with key %s.\n",
+		archivePath,
+		digest,
+		sig.PublicKeyFingerprint,
+	)
+
+	prov, err := readProvenance(archive)
+	if err != nil {
+		return nil, err
+	}
+	if prov != nil {
+		fmt.Fprintf(os.Stderr, "Bundle provenance: %s\n", prov)
+	}
+	return []workflow.Data{}, nil
+}
@@ -0,0 +1,80 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relation
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	flagName  = "name"
+	flagInput = "input"
+)
+
+func RegisterWorkflows(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.relation.test")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-relation-test", pflag.ExitOnError)
+
+	flagset.String(flagName, "", "Name of the relation to test")
+	flagset.String(flagInput, "", "Input file to evaluate the relation against, e.g. a spec input")
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, testWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func testWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	config := ictx.GetConfiguration()
+	name := config.GetString(flagName)
+	inputPath := config.GetString(flagInput)
+	if name == "" {
+		return nil, fmt.Errorf("--%s is required", flagName)
+	}
+	if inputPath == "" {
+		return nil, fmt.Errorf("--%s is required", flagInput)
+	}
+
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
+	input, err := utils.LoadSingleInput(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := prj.RelationPairs(name, input.State)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range pairs {
+		fmt.Fprintf(os.Stdout, "%s -> %s\n", pair.Left, pair.Right)
+	}
+	fmt.Fprintf(os.Stderr, "Relation %s joins %d pairs of resources in %s.\n", name, len(pairs), inputPath)
+	return []workflow.Data{}, nil
+}
@@ -0,0 +1,146 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+func RegisterWorkflows(e workflow.Engine) error {
+	if err := registerRemoveRuleWorkflow(e); err != nil {
+		return err
+	}
+	return registerRemoveSpecWorkflow(e)
+}
+
+func registerRemoveRuleWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.remove.rule")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-remove-rule", pflag.ExitOnError)
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, removeRuleWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func registerRemoveSpecWorkflow(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.remove.spec")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-remove-spec", pflag.ExitOnError)
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, removeSpecWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func removeRuleWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	logger := ictx.GetLogger()
+	args := utils.PositionalArgs(ictx.GetConfiguration())
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected the ID of the rule to remove as the argument, e.g. remove rule ACME-001")
+	}
+	ruleID := args[0]
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
+	// Relations that were unused before are not the removal's doing, so
+	// only the ones it leaves unused are reported.
+	unusedBefore, unusedErr := prj.UnusedRelations()
+	removed, err := prj.RemoveRule(ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeChanges(prj, removed); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Removed rule %s.\n", ruleID)
+
+	// A failure to check the relations shouldn't fail the removal, which
+	// has already been written at this point.
+	unusedAfter, err := prj.UnusedRelations()
+	if unusedErr != nil || err != nil {
+		logger.Println("failed to check for unused relations:", errors.Join(unusedErr, err))
+		return []workflow.Data{}, nil
+	}
+	for _, name := range orphanedRelations(unusedBefore, unusedAfter) {
+		fmt.Fprintf(os.Stderr, "Warning: relation %s in lib/relations.rego is no longer used by any rule\n", name)
+	}
+	return []workflow.Data{}, nil
+}
+
+// orphanedRelations returns the relations that are unused after a removal but
+// were used before it.
+func orphanedRelations(before []string, after []string) []string {
+	unused := map[string]bool{}
+	for _, name := range before {
+		unused[name] = true
+	}
+	var orphaned []string
+	for _, name := range after {
+		if !unused[name] {
+			orphaned = append(orphaned, name)
+		}
+	}
+	return orphaned
+}
+
+func removeSpecWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	args := utils.PositionalArgs(ictx.GetConfiguration())
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected the rule ID and the name of the spec as arguments, e.g. remove spec ACME-001 infra.tf")
+	}
+	ruleID, name := args[0], args[1]
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
+	removed, err := prj.RemoveRuleSpec(ruleID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeChanges(prj, removed); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Removed spec %s of rule %s.\n", name, ruleID)
+	return []workflow.Data{}, nil
+}
+
+func writeChanges(prj *project.Project, removed []string) error {
+	if err := prj.WriteChanges(); err != nil {
+		return err
+	}
+	for _, path := range removed {
+		fmt.Fprintf(os.Stderr, "Removed %s\n", path)
+	}
+	return nil
+}
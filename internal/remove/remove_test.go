@@ -0,0 +1,51 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remove
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphanedRelations(t *testing.T) {
+	testCases := []struct {
+		name     string
+		before   []string
+		after    []string
+		expected []string
+	}{
+		{
+			name: "nothing unused",
+		},
+		{
+			name:     "unused before",
+			before:   []string{"bucket_logging"},
+			after:    []string{"bucket_logging"},
+			expected: nil,
+		},
+		{
+			name:     "orphaned by the removal",
+			before:   []string{"bucket_logging"},
+			after:    []string{"bucket_acl", "bucket_logging"},
+			expected: []string{"bucket_acl"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, orphanedRelations(tc.before, tc.after))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rename
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/snyk/go-application-framework/pkg/configuration"
+	"github.com/snyk/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/init/forms"
+	"github.com/snyk/cli-extension-iac-rules/internal/project"
+	"github.com/snyk/cli-extension-iac-rules/internal/test"
+	"github.com/snyk/cli-extension-iac-rules/internal/utils"
+)
+
+const flagSkipTests = "skip-tests"
+
+func RegisterWorkflows(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.rename")
+	flagset := pflag.NewFlagSet("snyk-cli-extension-iac-rules-rename", pflag.ExitOnError)
+
+	flagset.Bool(flagSkipTests, false, "Do not run the specs and rego tests after renaming")
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, renameWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func renameWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	ctx := context.Background()
+	config := ictx.GetConfiguration()
+	args := utils.PositionalArgs(config)
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected the current and the new rule ID as arguments, e.g. rename ACME-001 ACME-002")
+	}
+	from, to := args[0], args[1]
+
+	fsys := afero.NewOsFs()
+	prj, err := project.FromDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if err := forms.ValidateRuleID(prj, to, from); err != nil {
+		return nil, fmt.Errorf("invalid rule ID %s: %w", to, err)
+	}
+	changed, err := prj.RenameRule(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := prj.WriteChanges(); err != nil {
+		return nil, err
+	}
+	for _, path := range changed {
+		fmt.Fprintf(os.Stderr, "Updated %s\n", path)
+	}
+	fmt.Fprintf(os.Stderr, "Renamed rule %s to %s.\n", from, to)
+
+	if config.GetBool(flagSkipTests) {
+		return []workflow.Data{}, nil
+	}
+	// The project is reloaded so that the tests see the moved directories.
+	prj, err = project.FromDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	summary, err := test.Run(ctx, prj, test.Options{
+		Verbose: config.GetBool(configuration.DEBUG),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !summary.Passed() {
+		return nil, fmt.Errorf("tests failed after renaming (%s), check the output above or run the tests with --update-expected", summary)
+	}
+	return []workflow.Data{}, nil
+}
@@ -0,0 +1,93 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// requestIDHeader is the response header carrying the ID that Snyk support
+// uses to look up a request.
+const requestIDHeader = "snyk-request-id"
+
+// APIError is returned when the API responds with an unexpected status code.
+type APIError struct {
+	StatusCode int
+	// RequestID identifies the request for Snyk support, if the API returned
+	// one.
+	RequestID string
+	// Errors holds the JSON:API error objects of the response.
+	Errors []ErrorObject
+	// Body holds the raw response body if it was not a JSON:API error
+	// document. This can happen when the request is rejected before reaching
+	// the service.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	var msg string
+	switch {
+	case len(e.Errors) > 0:
+		msgs := []string{}
+		for _, obj := range e.Errors {
+			msgs = append(msgs, errorObjectToString(obj))
+		}
+		msg = strings.Join(msgs, "\n")
+	case e.Body != "":
+		msg = fmt.Sprintf("response %d: %s", e.StatusCode, e.Body)
+	default:
+		msg = "unknown error"
+	}
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%s\nrequest ID: %s", msg, e.RequestID)
+	}
+	return msg
+}
+
+// IsNotFound returns true if err is an APIError with a 404 status.
+func IsNotFound(err error) bool {
+	return hasStatusCode(err, http.StatusNotFound)
+}
+
+// IsForbidden returns true if err is an APIError with a 403 status.
+func IsForbidden(err error) bool {
+	return hasStatusCode(err, http.StatusForbidden)
+}
+
+// IsPreconditionFailed returns true if err is an APIError with a 412 status.
+func IsPreconditionFailed(err error) bool {
+	return hasStatusCode(err, http.StatusPreconditionFailed)
+}
+
+// IsConflict returns true if err is an APIError with a 409 status.
+func IsConflict(err error) bool {
+	return hasStatusCode(err, http.StatusConflict)
+}
+
+func hasStatusCode(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+func errorObjectToString(err ErrorObject) string {
+	msg := fmt.Sprintf("%s %s: %s", err.Status, err.Title, err.Detail)
+	if err.Source != nil && err.Source.Pointer != "" {
+		msg = fmt.Sprintf("%s (at %s)", msg, err.Source.Pointer)
+	}
+	return msg
+}
@@ -0,0 +1,104 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResponseErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		requestID       string
+		body            string
+		expectedError   *APIError
+		expectedMessage string
+	}{
+		{
+			name:   "json api errors",
+			status: http.StatusBadRequest,
+			body: `{"errors":[{"status":"400","title":"Bad Request","detail":"invalid rego","code":"SNYK-0001",` +
+				`"source":{"pointer":"rules/TEST_001/main.rego"},"meta":{"line":3}}]}`,
+			requestID: "abc-123",
+			expectedError: &APIError{
+				StatusCode: http.StatusBadRequest,
+				RequestID:  "abc-123",
+				Errors: []ErrorObject{
+					{
+						Status: "400",
+						Title:  "Bad Request",
+						Detail: "invalid rego",
+						Code:   "SNYK-0001",
+						Source: &ErrorSource{Pointer: "rules/TEST_001/main.rego"},
+						Meta:   meta{"line": float64(3)},
+					},
+				},
+			},
+			expectedMessage: "400 Bad Request: invalid rego (at rules/TEST_001/main.rego)\nrequest ID: abc-123",
+		},
+		{
+			name:   "plain text body",
+			status: http.StatusBadGateway,
+			body:   "upstream unavailable",
+			expectedError: &APIError{
+				StatusCode: http.StatusBadGateway,
+				Body:       "upstream unavailable",
+			},
+			expectedMessage: "response 502: upstream unavailable",
+		},
+		{
+			name:   "no error objects",
+			status: http.StatusNotFound,
+			body:   `{"errors":[]}`,
+			expectedError: &APIError{
+				StatusCode: http.StatusNotFound,
+				Errors:     []ErrorObject{},
+			},
+			expectedMessage: "unknown error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.requestID != "" {
+				rsp.Header.Set(requestIDHeader, tt.requestID)
+			}
+			err := parseResponse(rsp, http.StatusOK, nil)
+			require.Equal(t, tt.expectedError, err)
+			require.EqualError(t, err, tt.expectedMessage)
+		})
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	notFound := &APIError{StatusCode: http.StatusNotFound}
+	require.True(t, IsNotFound(notFound))
+	require.True(t, IsNotFound(fmt.Errorf("updating: %w", notFound)))
+	require.False(t, IsNotFound(&APIError{StatusCode: http.StatusForbidden}))
+	require.False(t, IsNotFound(fmt.Errorf("not found")))
+	require.True(t, IsForbidden(&APIError{StatusCode: http.StatusForbidden}))
+}
@@ -39,24 +39,24 @@ type attributes interface{}
 // errorDocument represents a JSON API error document,
 type errorDocument struct {
 	JSONAPI jSONAPI       `json:"jsonapi"`
-	Errors  []errorObject `json:"errors"`
+	Errors  []ErrorObject `json:"errors"`
 }
 
-// errorObject represents a JSON API error object, as defined in
+// ErrorObject represents a JSON API error object, as defined in
 // https://jsonapi.org/format/#error-objects.
-type errorObject struct {
+type ErrorObject struct {
 	Status string `json:"status"`
 	Detail string `json:"detail"`
 
 	ID     string       `json:"id,omitempty"`
 	Code   string       `json:"code,omitempty"`
 	Title  string       `json:"title,omitempty"`
-	Source *errorSource `json:"source,omitempty"`
+	Source *ErrorSource `json:"source,omitempty"`
 	Meta   meta         `json:"meta,omitempty"`
 }
 
-// errorSource references the source of the error in the request.
-type errorSource struct {
+// ErrorSource references the source of the error in the request.
+type ErrorSource struct {
 	Pointer   string `json:"pointer"`
 	Parameter string `json:"parameter"`
 }
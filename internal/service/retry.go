@@ -0,0 +1,165 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+)
+
+// retryPolicy controls how often and how long the client waits before
+// retrying a request that failed with a transient error.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 5,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    30 * time.Second,
+}
+
+// do sends a request, retrying network errors, 5xx responses and 429s with an
+// exponential backoff. Requests that are not safe to repeat, POSTs and
+// conditional (If-Match) requests, are only retried on 429s and on errors
+// that occurred before the request was sent, since any other failure may
+// have been applied upstream. The body is rebuilt from the given bytes for
+// every attempt. Retries stop once the context is done or when the next
+// attempt would start after the context deadline, in which case the last
+// response or error is returned.
+func (c *Client) do(
+	ctx context.Context,
+	method string,
+	url string,
+	body []byte,
+	header http.Header,
+) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader = http.NoBody
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		var sent bool
+		trace := &httptrace.ClientTrace{
+			WroteHeaders: func() { sent = true },
+		}
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rsp, err := c.http.Do(req)
+
+		if attempt >= c.retry.maxAttempts || !retryable(ctx, req, rsp, err, sent) {
+			return rsp, err
+		}
+		delay := c.retry.backoff(attempt)
+		if rsp != nil {
+			if after, ok := retryAfter(rsp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = after
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return rsp, err
+		}
+		if rsp != nil {
+			// Drain the body so that the connection can be reused.
+			io.Copy(io.Discard, rsp.Body) //nolint:errcheck
+			rsp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable returns true if the outcome of a request indicates a transient
+// failure and the request can be sent again without applying it twice.
+func retryable(ctx context.Context, req *http.Request, rsp *http.Response, err error, sent bool) bool {
+	if err != nil {
+		// Errors caused by the context being cancelled or timing out are not
+		// transient.
+		if ctx.Err() != nil {
+			return false
+		}
+		return !sent || repeatable(req)
+	}
+	if rsp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return rsp.StatusCode >= 500 && repeatable(req)
+}
+
+// repeatable returns true if sending the request more than once has the same
+// effect as sending it once.
+func repeatable(req *http.Request) bool {
+	if req.Header.Get("If-Match") != "" {
+		// A conditional update that was applied before the failure would be
+		// rejected on a second attempt as if someone else had changed it.
+		return false
+	}
+	return req.Method != http.MethodPost
+}
+
+// backoff returns the delay before the given retry attempt: an exponentially
+// growing delay capped at maxDelay, of which the upper half is randomized.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.baseDelay << (attempt - 1)
+	if delay > p.maxDelay || delay <= 0 {
+		delay = p.maxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// retryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
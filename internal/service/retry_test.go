@@ -0,0 +1,312 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = retryPolicy{
+	maxAttempts: 3,
+	baseDelay:   time.Millisecond,
+	maxDelay:    5 * time.Millisecond,
+}
+
+type testResponse struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+func TestRetries(t *testing.T) {
+	created := testResponse{
+		status: http.StatusCreated,
+		body:   fmt.Sprintf(`{"data":{"id": "%s"}}`, bundleId),
+	}
+	ok := testResponse{
+		status: http.StatusOK,
+		body:   fmt.Sprintf(`{"data":{"id": "%s"}}`, bundleId),
+	}
+	create := func(ctx context.Context, client *Client) error {
+		_, err := client.CreateCustomRules(ctx, orgId, []byte("bundle"))
+		return err
+	}
+	get := func(ctx context.Context, client *Client) error {
+		_, err := client.GetCustomRules(ctx, orgId, bundleId)
+		return err
+	}
+	update := func(ifMatch string) func(context.Context, *Client) error {
+		return func(ctx context.Context, client *Client) error {
+			_, err := client.UpdateCustomRules(ctx, orgId, bundleId, ifMatch, []byte("bundle"))
+			return err
+		}
+	}
+	tests := []struct {
+		name             string
+		call             func(context.Context, *Client) error
+		responses        []testResponse
+		expectedError    bool
+		expectedAttempts int
+	}{
+		{
+			name:             "no retry on success",
+			call:             create,
+			responses:        []testResponse{created},
+			expectedAttempts: 1,
+		},
+		{
+			name: "retries gateway errors",
+			call: get,
+			responses: []testResponse{
+				{status: http.StatusBadGateway, body: "bad gateway"},
+				{status: http.StatusGatewayTimeout, body: "gateway timeout"},
+				ok,
+			},
+			expectedAttempts: 3,
+		},
+		{
+			name: "honors Retry-After on 429",
+			call: create,
+			responses: []testResponse{
+				{status: http.StatusTooManyRequests, retryAfter: "0"},
+				created,
+			},
+			expectedAttempts: 2,
+		},
+		{
+			name: "gives up after max attempts",
+			call: get,
+			responses: []testResponse{
+				{status: http.StatusServiceUnavailable, body: "unavailable"},
+				{status: http.StatusServiceUnavailable, body: "unavailable"},
+				{status: http.StatusServiceUnavailable, body: "unavailable"},
+				ok,
+			},
+			expectedError:    true,
+			expectedAttempts: 3,
+		},
+		{
+			name: "no retry on client errors",
+			call: get,
+			responses: []testResponse{
+				{status: http.StatusForbidden, body: `{"errors":[{"status":"403","detail":"Forbidden"}]}`},
+				ok,
+			},
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+		{
+			name: "no retry of create on server errors",
+			call: create,
+			responses: []testResponse{
+				{status: http.StatusBadGateway, body: "bad gateway"},
+				created,
+			},
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+		{
+			name: "retries unconditional update on server errors",
+			call: update(""),
+			responses: []testResponse{
+				{status: http.StatusBadGateway, body: "bad gateway"},
+				ok,
+			},
+			expectedAttempts: 2,
+		},
+		{
+			name: "no retry of conditional update on server errors",
+			call: update(`"v1"`),
+			responses: []testResponse{
+				{status: http.StatusBadGateway, body: "bad gateway"},
+				ok,
+			},
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+		{
+			name: "retries conditional update on 429",
+			call: update(`"v1"`),
+			responses: []testResponse{
+				{status: http.StatusTooManyRequests, retryAfter: "0"},
+				ok,
+			},
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					body, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					require.Equal(t, "bundle", string(body))
+				}
+
+				rsp := tt.responses[attempts]
+				attempts++
+				if rsp.retryAfter != "" {
+					w.Header().Set("Retry-After", rsp.retryAfter)
+				}
+				w.WriteHeader(rsp.status)
+				w.Write([]byte(rsp.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(server.Client(), server.URL, false)
+			client.retry = testRetryPolicy
+
+			err := tt.call(context.Background(), client)
+			if tt.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedAttempts, attempts)
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryNetworkErrors(t *testing.T) {
+	t.Run("retries create when the request was not sent", func(t *testing.T) {
+		attempts := 0
+		transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return nil, errors.New("connection refused")
+		})
+		client := NewClient(&http.Client{Transport: transport}, "http://localhost", false)
+		client.retry = testRetryPolicy
+
+		_, err := client.CreateCustomRules(context.Background(), orgId, []byte("bundle"))
+		require.Error(t, err)
+		require.Equal(t, testRetryPolicy.maxAttempts, attempts)
+	})
+
+	// hangUp reads the request and closes the connection without responding,
+	// so it is unknown whether the request was applied.
+	hangUp := func(attempts *int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			*attempts++
+			io.ReadAll(r.Body) //nolint:errcheck
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+		}
+	}
+
+	t.Run("no retry of create after the request was sent", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(hangUp(&attempts))
+		defer server.Close()
+		client := NewClient(server.Client(), server.URL, false)
+		client.retry = testRetryPolicy
+
+		_, err := client.CreateCustomRules(context.Background(), orgId, []byte("bundle"))
+		require.Error(t, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("retries get after the request was sent", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(hangUp(&attempts))
+		defer server.Close()
+		client := NewClient(server.Client(), server.URL, false)
+		client.retry = testRetryPolicy
+
+		_, err := client.GetCustomRules(context.Background(), orgId, bundleId)
+		require.Error(t, err)
+		require.Equal(t, testRetryPolicy.maxAttempts, attempts)
+	})
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL, false)
+	client.retry = testRetryPolicy
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := client.DeleteCustomRules(ctx, orgId, bundleId)
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value         string
+		expectedDelay time.Duration
+		expectedOK    bool
+	}{
+		{value: ""},
+		{value: "garbage"},
+		{value: "-1"},
+		{value: "120", expectedDelay: 2 * time.Minute, expectedOK: true},
+		{value: "Thu, 01 Jun 2023 12:00:30 GMT", expectedDelay: 30 * time.Second, expectedOK: true},
+		{value: "Thu, 01 Jun 2023 11:00:00 GMT", expectedOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			delay, ok := retryAfter(tt.value, now)
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expectedDelay, delay)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := retryPolicy{
+		maxAttempts: 10,
+		baseDelay:   100 * time.Millisecond,
+		maxDelay:    time.Second,
+	}
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		delay := policy.backoff(attempt + 1)
+		require.GreaterOrEqual(t, delay, max/2)
+		require.Less(t, delay, max)
+	}
+}
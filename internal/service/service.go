@@ -15,33 +15,41 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 )
 
 const versionLegacyApi = "2023-05-22~experimental"
 const version = "2024-09-24~beta"
 
 type Client struct {
-	http *http.Client
-	url  string
+	http         *http.Client
+	url          string
 	iacNewEngine bool
+	retry        retryPolicy
 }
 
 func NewClient(http *http.Client, url string, iacNewEngine bool) *Client {
 	return &Client{
-		http: http,
-		url:  url,
+		http:         http,
+		url:          url,
 		iacNewEngine: iacNewEngine,
+		retry:        defaultRetryPolicy,
 	}
 }
 
-func (c *Client) CreateCustomRules(ctx context.Context, orgID string, targz []byte) (string, error) {
+// CustomRules describes an upstream custom rules bundle.
+type CustomRules struct {
+	ID string
+	// ETag identifies the current version of the bundle, if the API returns
+	// one.
+	ETag string
+}
+
+func (c *Client) CreateCustomRules(ctx context.Context, orgID string, targz []byte) (*CustomRules, error) {
 	url := fmt.Sprintf(
 		"%s/rest/orgs/%s/cloud/rule_bundles?version=%s",
 		c.url,
@@ -49,7 +57,7 @@ func (c *Client) CreateCustomRules(ctx context.Context, orgID string, targz []by
 		versionLegacyApi,
 	)
 
-	if (c.iacNewEngine) {
+	if c.iacNewEngine {
 		url = fmt.Sprintf(
 			"%s/hidden/orgs/%s/cloud/rule_bundles?version=%s",
 			c.url,
@@ -58,29 +66,53 @@ func (c *Client) CreateCustomRules(ctx context.Context, orgID string, targz []by
 		)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(targz))
+	rsp, err := c.do(ctx, http.MethodPost, url, targz, octetStreamHeader())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
-	rsp, err := c.http.Do(req)
-	if err != nil {
-		return "", err
+	return parseCustomRules(rsp, http.StatusCreated)
+}
+
+func (c *Client) GetCustomRules(
+	ctx context.Context,
+	orgID string,
+	customRulesID string,
+) (*CustomRules, error) {
+	url := fmt.Sprintf(
+		"%s/rest/orgs/%s/cloud/rule_bundles/%s?version=%s",
+		c.url,
+		orgID,
+		customRulesID,
+		versionLegacyApi,
+	)
+
+	if c.iacNewEngine {
+		url = fmt.Sprintf(
+			"%s/hidden/orgs/%s/cloud/rule_bundles/%s?version=%s",
+			c.url,
+			orgID,
+			customRulesID,
+			version,
+		)
 	}
 
-	var response resourceDocument
-	if err := parseResponse(rsp, http.StatusCreated, &response); err != nil {
-		return "", err
+	rsp, err := c.do(ctx, http.MethodGet, url, nil, nil)
+	if err != nil {
+		return nil, err
 	}
-	return response.Data.ID, nil
+	return parseCustomRules(rsp, http.StatusOK)
 }
 
+// UpdateCustomRules replaces the contents of an existing bundle. If ifMatch is
+// not empty, the update is only applied if the upstream bundle still has that
+// ETag. Otherwise, the API responds with a 412 status.
 func (c *Client) UpdateCustomRules(
 	ctx context.Context,
 	orgID string,
 	customRulesID string,
+	ifMatch string,
 	targz []byte,
-) error {
+) (*CustomRules, error) {
 	url := fmt.Sprintf(
 		"%s/rest/orgs/%s/cloud/rule_bundles/%s?version=%s",
 		c.url,
@@ -89,7 +121,7 @@ func (c *Client) UpdateCustomRules(
 		versionLegacyApi,
 	)
 
-	if (c.iacNewEngine) {
+	if c.iacNewEngine {
 		url = fmt.Sprintf(
 			"%s/hidden/orgs/%s/cloud/rule_bundles/%s?version=%s",
 			c.url,
@@ -99,17 +131,15 @@ func (c *Client) UpdateCustomRules(
 		)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewBuffer(targz))
-	if err != nil {
-		return err
+	header := octetStreamHeader()
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
-	rsp, err := c.http.Do(req)
+	rsp, err := c.do(ctx, http.MethodPatch, url, targz, header)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var response resourceDocument
-	return parseResponse(rsp, http.StatusOK, &response)
+	return parseCustomRules(rsp, http.StatusOK)
 }
 
 func (c *Client) DeleteCustomRules(
@@ -125,7 +155,7 @@ func (c *Client) DeleteCustomRules(
 		versionLegacyApi,
 	)
 
-	if (c.iacNewEngine) {
+	if c.iacNewEngine {
 		url = fmt.Sprintf(
 			"%s/hidden/orgs/%s/cloud/rule_bundles/%s?version=%s",
 			c.url,
@@ -135,17 +165,30 @@ func (c *Client) DeleteCustomRules(
 		)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, http.NoBody)
-	if err != nil {
-		return err
-	}
-	rsp, err := c.http.Do(req)
+	rsp, err := c.do(ctx, http.MethodDelete, url, nil, nil)
 	if err != nil {
 		return err
 	}
 	return parseResponse(rsp, http.StatusNoContent, nil)
 }
 
+func octetStreamHeader() http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	return header
+}
+
+func parseCustomRules(rsp *http.Response, expectedStatusCode int) (*CustomRules, error) {
+	var response resourceDocument
+	if err := parseResponse(rsp, expectedStatusCode, &response); err != nil {
+		return nil, err
+	}
+	return &CustomRules{
+		ID:   response.Data.ID,
+		ETag: rsp.Header.Get("ETag"),
+	}, nil
+}
+
 func parseResponse(rsp *http.Response, expectedStatusCode int, expectedDocument interface{}) error {
 	body, err := io.ReadAll(rsp.Body)
 	defer rsp.Body.Close()
@@ -154,34 +197,24 @@ func parseResponse(rsp *http.Response, expectedStatusCode int, expectedDocument
 	}
 
 	if rsp.StatusCode != expectedStatusCode {
+		apiErr := &APIError{
+			StatusCode: rsp.StatusCode,
+			RequestID:  rsp.Header.Get(requestIDHeader),
+		}
 		var errorDoc errorDocument
 		if err := json.Unmarshal(body, &errorDoc); err != nil {
 			// If the error is not encoded as JSON, that is less important a detail to
 			// surface to the user than the actual content of the error. Notably, this
 			// can occur when cerberus bounces the request, as it returns plain text
 			// bodies.
-			return fmt.Errorf("response %d: %s", rsp.StatusCode, string(body))
+			apiErr.Body = string(body)
+		} else {
+			apiErr.Errors = errorDoc.Errors
 		}
-		return fmt.Errorf("%s", errorDocumentToString(errorDoc))
+		return apiErr
 	}
 	if expectedDocument != nil {
 		return json.Unmarshal(body, expectedDocument)
 	}
 	return nil
 }
-
-func errorDocumentToString(err errorDocument) string {
-	msgs := []string{}
-	if len(err.Errors) == 0 {
-		msgs = append(msgs, "unknown error")
-	} else {
-		for _, obj := range err.Errors {
-			msgs = append(msgs, errorObjectToString(obj))
-		}
-	}
-	return strings.Join(msgs, "\n")
-}
-
-func errorObjectToString(err errorObject) string {
-	return fmt.Sprintf("%s %s: %s", err.Status, err.Title, err.Detail)
-}
@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	_ "embed"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -49,7 +48,10 @@ func TestCreateCustomRules(t *testing.T) {
 			status:          http.StatusForbidden,
 			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles`, orgId),
 			expectedVersion: "2023-05-22~experimental",
-			expectedError:   errors.New("403 : Forbidden"),
+			expectedError: &APIError{
+				StatusCode: http.StatusForbidden,
+				Errors:     []ErrorObject{{Status: "403", Detail: "Forbidden"}},
+			},
 		},
 	}
 
@@ -75,7 +77,11 @@ func TestCreateCustomRules(t *testing.T) {
 
 			result, err := client.CreateCustomRules(context.Background(), orgId, make([]byte, 0))
 			require.Equal(t, tt.expectedError, err)
-			require.Equal(t, tt.expectedResult, result)
+			if tt.expectedResult == "" {
+				require.Nil(t, result)
+			} else {
+				require.Equal(t, tt.expectedResult, result.ID)
+			}
 		})
 	}
 }
@@ -85,8 +91,11 @@ func TestUpdateCustomRules(t *testing.T) {
 		name            string
 		response        string
 		status          int
+		ifMatch         string
+		etag            string
 		expectedPath    string
 		expectedVersion string
+		expectedResult  *CustomRules
 		expectedError   error
 		iacNewEngine    bool
 	}{
@@ -96,6 +105,7 @@ func TestUpdateCustomRules(t *testing.T) {
 			status:          http.StatusOK,
 			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
 			expectedVersion: "2023-05-22~experimental",
+			expectedResult:  &CustomRules{ID: bundleId},
 		},
 		{
 			name:            "new API success",
@@ -103,15 +113,41 @@ func TestUpdateCustomRules(t *testing.T) {
 			status:          http.StatusOK,
 			expectedPath:    fmt.Sprintf(`/hidden/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
 			expectedVersion: "2024-09-24~beta",
+			expectedResult:  &CustomRules{ID: bundleId},
 			iacNewEngine:    true,
 		},
+		{
+			name:            "conditional update",
+			response:        fmt.Sprintf(`{"data":{"id": "%s"}}`, bundleId),
+			status:          http.StatusOK,
+			ifMatch:         `"v1"`,
+			etag:            `"v2"`,
+			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
+			expectedVersion: "2023-05-22~experimental",
+			expectedResult:  &CustomRules{ID: bundleId, ETag: `"v2"`},
+		},
+		{
+			name:            "precondition failed",
+			response:        `{"errors":[{"status":"412","detail":"Precondition Failed"}]}`,
+			status:          http.StatusPreconditionFailed,
+			ifMatch:         `"v1"`,
+			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
+			expectedVersion: "2023-05-22~experimental",
+			expectedError: &APIError{
+				StatusCode: http.StatusPreconditionFailed,
+				Errors:     []ErrorObject{{Status: "412", Detail: "Precondition Failed"}},
+			},
+		},
 		{
 			name:            "forbidden",
 			response:        `{"errors":[{"status":"403","detail":"Forbidden"}]}`,
 			status:          http.StatusForbidden,
 			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
 			expectedVersion: "2023-05-22~experimental",
-			expectedError:   errors.New("403 : Forbidden"),
+			expectedError: &APIError{
+				StatusCode: http.StatusForbidden,
+				Errors:     []ErrorObject{{Status: "403", Detail: "Forbidden"}},
+			},
 		},
 	}
 
@@ -121,7 +157,11 @@ func TestUpdateCustomRules(t *testing.T) {
 				require.Equal(t, tt.expectedPath, r.URL.Path)
 				require.Equal(t, http.MethodPatch, r.Method)
 				require.Equal(t, tt.expectedVersion, r.URL.Query().Get("version"))
+				require.Equal(t, tt.ifMatch, r.Header.Get("If-Match"))
 
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
 				w.WriteHeader(tt.status)
 				w.Write([]byte(tt.response))
 				w.Header().Add("Content-Type", "application/json")
@@ -135,8 +175,81 @@ func TestUpdateCustomRules(t *testing.T) {
 				tt.iacNewEngine,
 			)
 
-			err := client.UpdateCustomRules(context.Background(), orgId, bundleId, make([]byte, 0))
+			result, err := client.UpdateCustomRules(context.Background(), orgId, bundleId, tt.ifMatch, make([]byte, 0))
+			require.Equal(t, tt.expectedError, err)
+			require.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
+
+func TestGetCustomRules(t *testing.T) {
+	tests := []struct {
+		name            string
+		response        string
+		status          int
+		etag            string
+		expectedPath    string
+		expectedVersion string
+		expectedResult  *CustomRules
+		expectedError   error
+		iacNewEngine    bool
+	}{
+		{
+			name:            "old API success",
+			response:        fmt.Sprintf(`{"data":{"id": "%s"}}`, bundleId),
+			status:          http.StatusOK,
+			etag:            `"v1"`,
+			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
+			expectedVersion: "2023-05-22~experimental",
+			expectedResult:  &CustomRules{ID: bundleId, ETag: `"v1"`},
+		},
+		{
+			name:            "new API success",
+			response:        fmt.Sprintf(`{"data":{"id": "%s"}}`, bundleId),
+			status:          http.StatusOK,
+			expectedPath:    fmt.Sprintf(`/hidden/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
+			expectedVersion: "2024-09-24~beta",
+			expectedResult:  &CustomRules{ID: bundleId},
+			iacNewEngine:    true,
+		},
+		{
+			name:            "not found",
+			response:        `{"errors":[{"status":"404","detail":"Not Found"}]}`,
+			status:          http.StatusNotFound,
+			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
+			expectedVersion: "2023-05-22~experimental",
+			expectedError: &APIError{
+				StatusCode: http.StatusNotFound,
+				Errors:     []ErrorObject{{Status: "404", Detail: "Not Found"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, tt.expectedPath, r.URL.Path)
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, tt.expectedVersion, r.URL.Query().Get("version"))
+
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+
+			defer server.Close()
+
+			client := NewClient(
+				server.Client(),
+				server.URL,
+				tt.iacNewEngine,
+			)
+
+			result, err := client.GetCustomRules(context.Background(), orgId, bundleId)
 			require.Equal(t, tt.expectedError, err)
+			require.Equal(t, tt.expectedResult, result)
 		})
 	}
 }
@@ -172,7 +285,10 @@ func TestDeleteCustomRules(t *testing.T) {
 			status:          http.StatusForbidden,
 			expectedPath:    fmt.Sprintf(`/rest/orgs/%s/cloud/rule_bundles/%s`, orgId, bundleId),
 			expectedVersion: "2023-05-22~experimental",
-			expectedError:   errors.New("403 : Forbidden"),
+			expectedError: &APIError{
+				StatusCode: http.StatusForbidden,
+				Errors:     []ErrorObject{{Status: "403", Detail: "Forbidden"}},
+			},
 		},
 	}
 
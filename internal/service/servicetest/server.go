@@ -0,0 +1,336 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package servicetest provides an in-process fake of the rule bundle endpoints
+// of the Snyk API, for testing code that uses the service client. Point the
+// client, or API_URL, at Server.URL.
+package servicetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	versionLegacyAPI = "2023-05-22~experimental"
+	version          = "2024-09-24~beta"
+)
+
+// Bundle is a rule bundle stored by the fake server.
+type Bundle struct {
+	ID             string
+	OrganizationID string
+	Contents       []byte
+	ETag           string
+	// Hidden is true if the bundle was last written through the /hidden API
+	// used by the new IaC engine.
+	Hidden bool
+}
+
+// Request records a request received by the fake server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+}
+
+type failure struct {
+	method string
+	status int
+	header http.Header
+}
+
+// Server is a fake of the rule bundle endpoints that stores bundles in memory.
+// It serves both the legacy /rest API and the /hidden API and checks that
+// requests use the matching version.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	bundles   map[string]*Bundle
+	failures  []failure
+	requests  []Request
+	revisions int
+	ids       int
+	omitETags bool
+	oneBundle bool
+}
+
+// NewServer starts a fake server. Callers should call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		bundles: map[string]*Bundle{},
+	}
+	mux := http.NewServeMux()
+	for _, api := range []struct {
+		prefix  string
+		version string
+		hidden  bool
+	}{
+		{prefix: "/rest", version: versionLegacyAPI},
+		{prefix: "/hidden", version: version, hidden: true},
+	} {
+		collection := api.prefix + "/orgs/{org}/cloud/rule_bundles"
+		item := collection + "/{id}"
+		mux.Handle("POST "+collection, s.handler(api.version, api.hidden, s.create))
+		mux.Handle("GET "+item, s.handler(api.version, api.hidden, s.get))
+		mux.Handle("PATCH "+item, s.handler(api.version, api.hidden, s.update))
+		mux.Handle("DELETE "+item, s.handler(api.version, api.hidden, s.delete))
+	}
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddBundle stores a bundle as if it had been pushed earlier and returns it.
+func (s *Server) AddBundle(orgID string, contents []byte) Bundle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.put(&Bundle{OrganizationID: orgID}, contents, false)
+}
+
+// ReplaceBundle replaces the contents of a bundle as if someone else had
+// pushed to it, and returns the updated bundle.
+func (s *Server) ReplaceBundle(id string, contents []byte) (Bundle, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bundles[id]
+	if !ok {
+		return Bundle{}, false
+	}
+	return *s.put(b, contents, b.Hidden), true
+}
+
+// Bundle returns the bundle with the given ID in the given organization.
+func (s *Server) Bundle(orgID string, id string) (Bundle, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bundles[id]
+	if !ok || b.OrganizationID != orgID {
+		return Bundle{}, false
+	}
+	return *b, true
+}
+
+// Bundles returns all bundles of the given organization, ordered by ID.
+func (s *Server) Bundles(orgID string) []Bundle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bundles := []Bundle{}
+	for _, b := range s.bundles {
+		if b.OrganizationID == orgID {
+			bundles = append(bundles, *b)
+		}
+	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].ID < bundles[j].ID
+	})
+	return bundles
+}
+
+// DeleteBundle removes a bundle as if it had been deleted in the UI.
+func (s *Server) DeleteBundle(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bundles, id)
+}
+
+// FailNext makes the next n requests with the given method fail with the
+// given status. An empty method matches any request. The optional header is
+// added to the failed responses, e.g. to set Retry-After.
+func (s *Server) FailNext(method string, status int, n int, header http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{
+			method: method,
+			status: status,
+			header: header,
+		})
+	}
+}
+
+// OmitETags makes the server behave like an API without ETag support: it
+// stops sending ETag headers and ignores If-Match.
+func (s *Server) OmitETags() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.omitETags = true
+}
+
+// LimitOneBundle makes the server reject creating a bundle in an
+// organization that already has one with a 409 status.
+func (s *Server) LimitOneBundle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oneBundle = true
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, hidden bool)
+
+func (s *Server) handler(version string, hidden bool, next handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+		})
+		w.Header().Set("snyk-request-id", fmt.Sprintf("fake-request-%d", len(s.requests)))
+
+		if f, ok := s.takeFailure(r.Method); ok {
+			for key, values := range f.header {
+				w.Header()[key] = values
+			}
+			writeError(w, f.status, "injected failure")
+			return
+		}
+		if got := r.URL.Query().Get("version"); got != version {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported version %q", got))
+			return
+		}
+		next(w, r, hidden)
+	})
+}
+
+func (s *Server) takeFailure(method string) (failure, bool) {
+	for i, f := range s.failures {
+		if f.method == "" || f.method == method {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f, true
+		}
+	}
+	return failure{}, false
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, hidden bool) {
+	contents, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.oneBundle {
+		for _, b := range s.bundles {
+			if b.OrganizationID == r.PathValue("org") {
+				writeError(w, http.StatusConflict, "organization already has a bundle")
+				return
+			}
+		}
+	}
+	b := s.put(&Bundle{OrganizationID: r.PathValue("org")}, contents, hidden)
+	s.writeBundle(w, http.StatusCreated, b)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, _ bool) {
+	b, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	s.writeBundle(w, http.StatusOK, b)
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, hidden bool) {
+	b, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !s.omitETags && ifMatch != b.ETag {
+		writeError(w, http.StatusPreconditionFailed, "bundle was modified")
+		return
+	}
+	contents, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.writeBundle(w, http.StatusOK, s.put(b, contents, hidden))
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, _ bool) {
+	b, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	delete(s.bundles, b.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*Bundle, bool) {
+	b, ok := s.bundles[r.PathValue("id")]
+	if !ok || b.OrganizationID != r.PathValue("org") {
+		writeError(w, http.StatusNotFound, "rule bundle not found")
+		return nil, false
+	}
+	return b, true
+}
+
+// put stores new contents for the given bundle, assigning an ID to new
+// bundles and a new ETag.
+func (s *Server) put(b *Bundle, contents []byte, hidden bool) *Bundle {
+	if b.ID == "" {
+		s.ids++
+		b.ID = fmt.Sprintf("bundle-%d", s.ids)
+	}
+	s.revisions++
+	b.Contents = contents
+	b.ETag = strconv.Quote(strconv.Itoa(s.revisions))
+	b.Hidden = hidden
+	s.bundles[b.ID] = b
+	return b
+}
+
+func (s *Server) writeBundle(w http.ResponseWriter, status int, b *Bundle) {
+	if !s.omitETags {
+		w.Header().Set("ETag", b.ETag)
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"data": map[string]interface{}{
+			"id":   b.ID,
+			"type": "rule_bundle",
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"errors": []map[string]string{
+			{
+				"status": strconv.Itoa(status),
+				"title":  http.StatusText(status),
+				"detail": detail,
+			},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body) //nolint:errcheck
+}
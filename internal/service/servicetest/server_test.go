@@ -0,0 +1,90 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicetest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/snyk/cli-extension-iac-rules/internal/service"
+)
+
+func TestServerRoundTrip(t *testing.T) {
+	for _, iacNewEngine := range []bool{false, true} {
+		server := NewServer()
+		defer server.Close()
+		client := service.NewClient(server.Client(), server.URL, iacNewEngine)
+		ctx := context.Background()
+
+		created, err := client.CreateCustomRules(ctx, "org-1", []byte("v1"))
+		require.NoError(t, err)
+		fetched, err := client.GetCustomRules(ctx, "org-1", created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, created, fetched)
+
+		updated, err := client.UpdateCustomRules(ctx, "org-1", created.ID, created.ETag, []byte("v2"))
+		require.NoError(t, err)
+		assert.NotEqual(t, created.ETag, updated.ETag)
+		_, err = client.UpdateCustomRules(ctx, "org-1", created.ID, created.ETag, []byte("v3"))
+		assert.True(t, service.IsPreconditionFailed(err))
+
+		b, ok := server.Bundle("org-1", created.ID)
+		require.True(t, ok)
+		assert.Equal(t, []byte("v2"), b.Contents)
+		assert.Equal(t, iacNewEngine, b.Hidden)
+
+		_, err = client.GetCustomRules(ctx, "org-2", created.ID)
+		assert.True(t, service.IsNotFound(err))
+
+		require.NoError(t, client.DeleteCustomRules(ctx, "org-1", created.ID))
+		assert.Empty(t, server.Bundles("org-1"))
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := service.NewClient(server.Client(), server.URL, false)
+
+	server.FailNext("", http.StatusTooManyRequests, 2, http.Header{"Retry-After": {"0"}})
+	created, err := client.CreateCustomRules(context.Background(), "org-1", []byte("v1"))
+	require.NoError(t, err)
+	assert.Len(t, server.Requests(), 3)
+
+	server.FailNext(http.MethodDelete, http.StatusForbidden, 1, nil)
+	err = client.DeleteCustomRules(context.Background(), "org-1", created.ID)
+	var apiErr *service.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "fake-request-4", apiErr.RequestID)
+}
+
+func TestServerChecksVersion(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	rsp, err := server.Client().Post(
+		server.URL+"/hidden/orgs/org-1/cloud/rule_bundles?version="+versionLegacyAPI,
+		"application/octet-stream",
+		nil,
+	)
+	require.NoError(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+}
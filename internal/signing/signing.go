@@ -0,0 +1,150 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signing signs rule bundle archives with ed25519 keys and verifies
+// those signatures. Keys are read from PEM files, as produced by e.g.
+// `openssl genpkey -algorithm ed25519`, so everything works offline.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/afero"
+)
+
+// ErrInvalidKey is returned when a key file does not contain an ed25519 key.
+var ErrInvalidKey = errors.New("invalid ed25519 key")
+
+// ErrInvalidSignature is returned when a signature does not match an archive.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signature is stored next to a signed archive.
+type Signature struct {
+	Signature            string `json:"signature"`
+	PublicKeyFingerprint string `json:"public_key_fingerprint"`
+}
+
+// SignaturePath returns the path where the signature for the given archive is
+// stored.
+func SignaturePath(archivePath string) string {
+	return archivePath + ".sig"
+}
+
+// LoadPrivateKey reads a PKCS #8 PEM encoded ed25519 private key.
+func LoadPrivateKey(fsys afero.Fs, path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(fsys, path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", ErrInvalidKey, path, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w %s: not an ed25519 private key", ErrInvalidKey, path)
+	}
+	return privateKey, nil
+}
+
+// LoadPublicKey reads a PKIX PEM encoded ed25519 public key.
+func LoadPublicKey(fsys afero.Fs, path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(fsys, path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %s", ErrInvalidKey, path, err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w %s: not an ed25519 public key", ErrInvalidKey, path)
+	}
+	return publicKey, nil
+}
+
+func readPEM(fsys afero.Fs, path string, blockType string) ([]byte, error) {
+	b, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%w %s: expected a PEM encoded %s", ErrInvalidKey, path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+// Fingerprint returns a fingerprint of the given public key in the same format
+// that ssh-keygen uses.
+func Fingerprint(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// Sign signs the given archive.
+func Sign(privateKey ed25519.PrivateKey, archive []byte) Signature {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return Signature{
+		Signature:            base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, archive)),
+		PublicKeyFingerprint: Fingerprint(publicKey),
+	}
+}
+
+// Verify checks that the signature was made for the given archive by the
+// private key belonging to the given public key.
+func Verify(publicKey ed25519.PublicKey, archive []byte, sig Signature) error {
+	if fingerprint := Fingerprint(publicKey); sig.PublicKeyFingerprint != fingerprint {
+		return fmt.Errorf(
+			"%w: signed with key %s but verifying with key %s",
+			ErrInvalidSignature,
+			sig.PublicKeyFingerprint,
+			fingerprint,
+		)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if !ed25519.Verify(publicKey, archive, raw) {
+		return fmt.Errorf("%w: archive has been modified or signature is corrupt", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Marshal encodes the signature for storage next to the archive.
+func (s Signature) Marshal() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// ReadSignature reads a signature that was stored next to an archive.
+func ReadSignature(fsys afero.Fs, path string) (Signature, error) {
+	var sig Signature
+	b, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return sig, err
+	}
+	if err := json.Unmarshal(b, &sig); err != nil {
+		return sig, fmt.Errorf("%w %s: %s", ErrInvalidSignature, path, err)
+	}
+	return sig, nil
+}
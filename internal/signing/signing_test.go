@@ -0,0 +1,87 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeys(t *testing.T, fsys afero.Fs, name string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	afero.WriteFile(fsys, name+".pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600)
+	afero.WriteFile(fsys, name+".pub.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	writeKeys(t, fsys, "key")
+	writeKeys(t, fsys, "other")
+	archive := []byte("archive")
+
+	privateKey, err := LoadPrivateKey(fsys, "key.pem")
+	require.NoError(t, err)
+	publicKey, err := LoadPublicKey(fsys, "key.pub.pem")
+	require.NoError(t, err)
+	otherKey, err := LoadPublicKey(fsys, "other.pub.pem")
+	require.NoError(t, err)
+
+	sig := Sign(privateKey, archive)
+	assert.Equal(t, Fingerprint(publicKey), sig.PublicKeyFingerprint)
+
+	b, err := sig.Marshal()
+	require.NoError(t, err)
+	afero.WriteFile(fsys, SignaturePath("bundle.tar.gz"), b, 0644)
+	stored, err := ReadSignature(fsys, "bundle.tar.gz.sig")
+	require.NoError(t, err)
+	assert.Equal(t, sig, stored)
+
+	t.Run("valid signature", func(t *testing.T) {
+		assert.NoError(t, Verify(publicKey, archive, stored))
+	})
+	t.Run("modified archive", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(publicKey, []byte("modified"), stored), ErrInvalidSignature)
+	})
+	t.Run("different key", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(otherKey, archive, stored), ErrInvalidSignature)
+	})
+}
+
+func TestLoadKeyErrors(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	writeKeys(t, fsys, "key")
+	afero.WriteFile(fsys, "garbage.pem", []byte("garbage"), 0644)
+
+	_, err := LoadPrivateKey(fsys, "key.pub.pem")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = LoadPublicKey(fsys, "key.pem")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = LoadPublicKey(fsys, "garbage.pem")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = LoadPrivateKey(fsys, "missing.pem")
+	assert.Error(t, err)
+}
@@ -61,19 +61,68 @@ func testWorkflow(
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
 	ctx := context.Background()
-	verbose := ictx.GetConfiguration().GetBool(configuration.DEBUG)
+	config := ictx.GetConfiguration()
 
-	fmt.Fprintln(os.Stderr, "Running specs...")
-
-	updateExpected := ictx.GetConfiguration().GetBool(flagUpdateExpected)
-	fixturesFailed := 0
-	fixturesTested := 0
+	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	if err != nil {
+		return nil, err
+	}
 
-	fs := afero.NewOsFs()
-	prj, err := project.FromDir(fs, ".")
+	summary, err := Run(ctx, prj, Options{
+		UpdateExpected: config.GetBool(flagUpdateExpected),
+		Verbose:        config.GetBool(configuration.DEBUG),
+	})
 	if err != nil {
 		return nil, err
 	}
+	if !summary.Passed() {
+		return nil, fmt.Errorf("tests failed")
+	}
+
+	return []workflow.Data{}, nil
+}
+
+// Options configures a test run.
+type Options struct {
+	// UpdateExpected overwrites the expected output of failing specs with the
+	// actual results.
+	UpdateExpected bool
+	Verbose        bool
+}
+
+// Summary describes the outcome of a test run.
+type Summary struct {
+	SpecsTested     int
+	SpecsFailed     int
+	RegoTestsPassed bool
+}
+
+// Passed returns true if both the specs and the rego tests passed.
+func (s *Summary) Passed() bool {
+	return s.SpecsFailed == 0 && s.RegoTestsPassed
+}
+
+func (s *Summary) String() string {
+	rego := "passed"
+	if !s.RegoTestsPassed {
+		rego = "failed"
+	}
+	return fmt.Sprintf(
+		"%d/%d specs passed, rego tests %s",
+		s.SpecsTested-s.SpecsFailed,
+		s.SpecsTested,
+		rego,
+	)
+}
+
+// Run runs the specs (snapshot tests) and the rego tests of the given project,
+// reporting progress and failures on stderr. Failing tests are reported in the
+// returned summary rather than as an error.
+func Run(ctx context.Context, prj *project.Project, options Options) (*Summary, error) {
+	fmt.Fprintln(os.Stderr, "Running specs...")
+
+	summary := &Summary{}
+	fs := prj.FS
 
 	eng, err := prj.Engine(ctx)
 	if err != nil {
@@ -111,13 +160,13 @@ func testWorkflow(
 		}
 
 		if expected != actual {
-			fixturesFailed += 1
+			summary.SpecsFailed += 1
 			edits := myers.ComputeEdits(span.URI(expectedPath), expected, actual)
 			diff := gotextdiff.ToUnified(expectedPath, fixture.Input.Path(), expected, edits)
 			fmt.Fprintf(os.Stderr, "expected output does not match for rule %s\n: %s", ruleID, diff)
 
-			if updateExpected {
-				if err := os.MkdirAll(filepath.Dir(expectedPath), 0755); err != nil {
+			if options.UpdateExpected {
+				if err := fs.MkdirAll(filepath.Dir(expectedPath), 0755); err != nil {
 					return nil, err
 				}
 				fixture.UpdateExpected(actualBytes)
@@ -127,27 +176,24 @@ func testWorkflow(
 			}
 		}
 
-		fixturesTested += 1
+		summary.SpecsTested += 1
 	}
 
-	fmt.Fprintf(os.Stderr, "%d/%d specs passed.\n", fixturesTested-fixturesFailed, fixturesTested)
+	fmt.Fprintf(os.Stderr, "%d/%d specs passed.\n", summary.SpecsTested-summary.SpecsFailed, summary.SpecsTested)
 
 	// As well as the "specs" (snapshot tests) we also use policy-engine/test to
 	// run custom rego tests.
 	fmt.Fprintln(os.Stderr, "Running rego tests...")
 	result, err := test.Test(ctx, test.Options{
 		Providers: prj.Providers(),
-		Verbose:   verbose,
+		Verbose:   options.Verbose,
 	})
 	if err != nil {
 		return nil, err
 	}
+	summary.RegoTestsPassed = result.Passed
 
-	if fixturesFailed > 0 || !result.Passed {
-		return nil, fmt.Errorf("tests failed")
-	}
-
-	return []workflow.Data{}, nil
+	return summary, nil
 }
 
 func makeRuleDirNameToRuleID(eng *engine.Engine, ctx context.Context) (map[string]string, error) {
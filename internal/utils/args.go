@@ -0,0 +1,38 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"github.com/snyk/go-application-framework/pkg/configuration"
+)
+
+// PositionalArgs returns the positional arguments of the command. The CLI
+// passes them in the input directory option, which defaults to the working
+// directory when there are none.
+func PositionalArgs(config configuration.Configuration) []string {
+	switch args := config.Get(configuration.INPUT_DIRECTORY).(type) {
+	case []string:
+		return args
+	case []interface{}:
+		var out []string
+		for _, arg := range args {
+			if s, ok := arg.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
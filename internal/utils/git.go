@@ -0,0 +1,46 @@
+// © 2023 Snyk Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// GitCommit returns the commit that is checked out in the git repository
+// containing dir, and whether the working tree has uncommitted changes. An
+// error is returned when git is not installed or dir is not in a repository.
+func GitCommit(dir string) (commit string, dirty bool, err error) {
+	out, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	commit = strings.TrimSpace(out)
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return "", false, err
+	}
+	return commit, strings.TrimSpace(status) != "", nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}